@@ -29,6 +29,14 @@ func initializeServer() {
 	}
 	configLock.Unlock()
 
+	// Seed the shared RNG so chaos decisions are reproducible
+	configLock.RLock()
+	if config.Seed != 0 {
+		rng.Seed(config.Seed)
+		log.Printf("Using random seed %d", config.Seed)
+	}
+	configLock.RUnlock()
+
 	// Initialize request history
 	configLock.RLock()
 	if config.HistorySize > 0 {
@@ -50,8 +58,9 @@ func initializeServer() {
 			var sc []Scenario
 			if err := yaml.Unmarshal(data, &sc); err == nil {
 				for _, s := range sc {
-					scenarios.Store(s.Path, s.Responses)
-					scenarioIndex.Store(s.Path, 0)
+					key := scenarioKey(s.Path, s.Operation)
+					scenarios.Store(key, s.Responses)
+					scenarioIndex.Store(key, 0)
 				}
 			} else {
 				log.Printf("Failed to parse scenario file: %v", err)
@@ -60,6 +69,42 @@ func initializeServer() {
 	}
 	configLock.RUnlock()
 
+	// Load proxy fault rules if specified
+	configLock.RLock()
+	if config.ProxyFaultFile != "" {
+		loadProxyFaults(config.ProxyFaultFile)
+	}
+	configLock.RUnlock()
+
+	// Load per-route slow read settings if specified
+	configLock.RLock()
+	if config.SlowReadFile != "" {
+		loadSlowReadRoutes(config.SlowReadFile)
+	}
+	configLock.RUnlock()
+
+	// Load route rules if specified
+	configLock.RLock()
+	if config.RulesFile != "" {
+		loadRules(config.RulesFile)
+	}
+	configLock.RUnlock()
+
+	// Load the GraphQL schema if specified
+	configLock.RLock()
+	if config.GraphQLSchema != "" {
+		loadGraphQLSchema(config.GraphQLSchema)
+	}
+	configLock.RUnlock()
+
 	// Register Prometheus metrics
 	registerPrometheusMetrics()
+
+	// Load outage schedules and keep their gauge current
+	configLock.RLock()
+	if config.ScheduleFile != "" {
+		loadSchedules(config.ScheduleFile)
+	}
+	configLock.RUnlock()
+	go runScheduleTicker()
 }
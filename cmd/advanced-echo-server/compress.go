@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// supportedEncodings lists the content codings in server preference order,
+// used to break ties between equal Accept-Encoding q-values.
+var supportedEncodings = []string{"br", "zstd", "gzip", "deflate"}
+
+var errUnsupportedEncoding = errors.New("unsupported content coding")
+
+// normalizeEncoding maps coding aliases to their canonical token.
+func normalizeEncoding(name string) string {
+	switch name = strings.ToLower(strings.TrimSpace(name)); name {
+	case "x-gzip":
+		return "gzip"
+	case "brotli":
+		return "br"
+	case "none", "":
+		return "identity"
+	}
+	return name
+}
+
+// newEncoder wraps w with the named content coding. "deflate" is the zlib
+// format, as HTTP defines it.
+func newEncoder(codec string, w io.Writer) (io.WriteCloser, error) {
+	switch codec {
+	case "gzip":
+		return gzip.NewWriter(w), nil
+	case "deflate":
+		return zlib.NewWriter(w), nil
+	case "br":
+		return brotli.NewWriter(w), nil
+	case "zstd":
+		return zstd.NewWriter(w)
+	}
+	return nil, fmt.Errorf("%w %q", errUnsupportedEncoding, codec)
+}
+
+// newDecoder unwraps r according to the named content coding.
+func newDecoder(codec string, r io.Reader) (io.ReadCloser, error) {
+	switch codec {
+	case "gzip":
+		return gzip.NewReader(r)
+	case "deflate":
+		return zlib.NewReader(r)
+	case "br":
+		return io.NopCloser(brotli.NewReader(r)), nil
+	case "zstd":
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("%w %q", errUnsupportedEncoding, codec)
+}
+
+// encodeBody compresses body with codec.
+func encodeBody(codec string, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	enc, err := newEncoder(codec, &buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := enc.Write(body); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// negotiateEncoding picks the best supported coding from an Accept-Encoding
+// header, honoring q-values and "*". It returns "identity" when nothing better
+// is acceptable.
+func negotiateEncoding(accept string) string {
+	if strings.TrimSpace(accept) == "" {
+		return "identity"
+	}
+	weights := map[string]float64{}
+	wildcard := -1.0
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if key, value, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.EqualFold(key, "q") {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if name = normalizeEncoding(name); name == "*" {
+			wildcard = q
+		} else {
+			weights[name] = q
+		}
+	}
+
+	best, bestQ := "identity", 0.0
+	for _, codec := range supportedEncodings {
+		q, listed := weights[codec]
+		if !listed {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = codec, q
+		}
+	}
+	return best
+}
+
+// compressResponse encodes an echo or scenario body. X-Echo-Compress / ECHO_COMPRESS
+// forces a coding ("gzip"), disables compression ("identity"), or labels the body
+// with a different coding than the one used ("gzip:br" sends gzip bytes marked as
+// br). Without it the coding is negotiated from Accept-Encoding.
+func compressResponse(w http.ResponseWriter, r *http.Request, body []byte) []byte {
+	if len(body) == 0 || w.Header().Get("Content-Encoding") != "" {
+		return body
+	}
+	codec, label := "", ""
+	if spec := getHeaderOrEnv(r, "X-Echo-Compress", "ECHO_COMPRESS"); spec != "" && !strings.EqualFold(spec, "auto") {
+		used, marked, mismatched := strings.Cut(spec, ":")
+		codec = normalizeEncoding(used)
+		label = codec
+		if mismatched {
+			label = normalizeEncoding(marked)
+		}
+	} else {
+		// "identity;q=0" or "*;q=0" can leave no acceptable coding. This answers
+		// uncompressed rather than 406, as RFC 9110 section 12.5.3 asks of a server
+		// that has no acceptable coding: echo clients expect their body back.
+		codec = negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		label = codec
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+
+	if codec != "identity" {
+		encoded, err := encodeBody(codec, body)
+		if err != nil {
+			log.Printf("Compression with %q failed: %v", codec, err)
+			return body
+		}
+		body = encoded
+	}
+	if label != "identity" {
+		w.Header().Set("Content-Encoding", label)
+	}
+	return body
+}
+
+// decodeRequestBody undoes the request's Content-Encoding so the decoded body is
+// echoed, recorded and forwarded. Stacked codings are removed in reverse order.
+func decodeRequestBody(r *http.Request, body []byte, limit int64) ([]byte, error) {
+	header := r.Header.Get("Content-Encoding")
+	if header == "" || len(body) == 0 {
+		return body, nil
+	}
+	codings := strings.Split(header, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		codec := normalizeEncoding(codings[i])
+		if codec == "identity" {
+			continue
+		}
+		dec, err := newDecoder(codec, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		body, err = io.ReadAll(io.LimitReader(dec, limit))
+		dec.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", codec, err)
+		}
+	}
+	r.Header.Del("Content-Encoding")
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	r.ContentLength = int64(len(body))
+	return body, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := map[string]string{
+		"":                             "identity",
+		"gzip":                         "gzip",
+		"gzip, deflate, br, zstd":      "br",
+		"gzip;q=0.5, deflate;q=0.8":    "deflate",
+		"br;q=0, *":                    "zstd",
+		"*;q=0.1, gzip;q=0.9":          "gzip",
+		"identity":                     "identity",
+		"gzip;q=0, deflate;q=0":        "identity",
+		"identity;q=0":                 "identity",
+		"*;q=0":                        "identity",
+		"x-gzip;q=1.0, compress;q=0.5": "gzip",
+	}
+	for accept, want := range cases {
+		if got := negotiateEncoding(accept); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}
+
+func decodeResponse(t *testing.T, codec string, body []byte) string {
+	t.Helper()
+	dec, err := newDecoder(codec, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("%s decoder: %v", codec, err)
+	}
+	defer dec.Close()
+	out, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("%s decode: %v", codec, err)
+	}
+	return string(out)
+}
+
+func TestResponseCompressionCodecs(t *testing.T) {
+	setupTest()
+	payload := strings.Repeat("negotiate me ", 40)
+	for _, codec := range []string{"gzip", "deflate", "br", "zstd"} {
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(payload))
+		req.Header.Set("Accept-Encoding", codec+", identity;q=0.5")
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+		if got := rr.Header().Get("Content-Encoding"); got != codec {
+			t.Fatalf("expected %s, got %q", codec, got)
+		}
+		if rr.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s: negotiated response should vary on Accept-Encoding", codec)
+		}
+		if got := decodeResponse(t, codec, rr.Body.Bytes()); got != payload {
+			t.Errorf("%s: round trip mismatch", codec)
+		}
+	}
+}
+
+func TestForcedAndMismatchedCompression(t *testing.T) {
+	setupTest()
+	payload := strings.Repeat("force ", 40)
+	serve := func(compress string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(payload))
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("X-Echo-Compress", compress)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve("zstd")
+	if rr.Header().Get("Content-Encoding") != "zstd" || decodeResponse(t, "zstd", rr.Body.Bytes()) != payload {
+		t.Error("forced zstd should ignore Accept-Encoding")
+	}
+	rr = serve("gzip:br")
+	if rr.Header().Get("Content-Encoding") != "br" || decodeResponse(t, "gzip", rr.Body.Bytes()) != payload {
+		t.Error("gzip:br should send gzip bytes labelled br")
+	}
+	rr = serve("identity:gzip")
+	if rr.Header().Get("Content-Encoding") != "gzip" || rr.Body.String() != payload {
+		t.Error("identity:gzip should label a plain body as gzip")
+	}
+	rr = serve("identity")
+	if rr.Header().Get("Content-Encoding") != "" || rr.Body.String() != payload {
+		t.Error("identity should disable compression")
+	}
+}
+
+func TestRequestBodyDecompression(t *testing.T) {
+	setupTest()
+	payload := `{"compressed": true}`
+	for _, codec := range []string{"gzip", "deflate", "br", "zstd"} {
+		encoded, err := encodeBody(codec, []byte(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := http.NewRequest("POST", "/", bytes.NewReader(encoded))
+		req.Header.Set("Content-Encoding", codec)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+		if rr.Body.String() != payload || rr.Header().Get("X-Echo-Request-Decoded") != codec {
+			t.Errorf("%s: expected decoded echo, got %q", codec, rr.Body.String())
+		}
+	}
+
+	req, _ := http.NewRequest("POST", "/", strings.NewReader("data"))
+	req.Header.Set("Content-Encoding", "compress")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("unknown coding should be 415, got %d", rr.Code)
+	}
+
+	req, _ = http.NewRequest("POST", "/", strings.NewReader("not gzip"))
+	req.Header.Set("Content-Encoding", "gzip")
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("corrupt body should be 400, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// conditionalHeaders are the request headers that make a request ranged or conditional.
+var conditionalHeaders = []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since"}
+
+func hasConditionalHeaders(r *http.Request) bool {
+	for _, name := range conditionalHeaders {
+		if r.Header.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// bytesETag returns a strong entity tag for content.
+func bytesETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// serveEntity writes a cacheable, rangeable 200 response with ETag and Last-Modified
+// validators. Range and conditional requests are answered by http.ServeContent
+// (206, multipart/byteranges, 416, 304 and 412); plain requests keep body pacing.
+// X-Echo-Validators / ECHO_VALIDATORS degrades the validators for negative tests:
+//
+//	stale     304 for every conditional request, whether or not it matches
+//	mismatch  new validators on every response, so nothing ever matches
+//	weak      weak ETag, which If-Range cannot use for partial responses
+//	none      no validators at all
+func serveEntity(w http.ResponseWriter, r *http.Request, content io.ReadSeeker, size int64, etag string, modTime time.Time, shape bodyShape) {
+	switch strings.ToLower(getHeaderOrEnv(r, "X-Echo-Validators", "ECHO_VALIDATORS")) {
+	case "stale":
+		if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+			log.Printf("Validators: answering stale 304 for %s", r.URL.Path)
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	case "mismatch":
+		etag = fmt.Sprintf(`"mismatch-%x"`, time.Now().UnixNano())
+		modTime = time.Now()
+		r.Header.Del("If-None-Match")
+		r.Header.Del("If-Modified-Since")
+	case "weak":
+		etag = "W/" + etag
+	case "none":
+		etag, modTime = "", time.Time{}
+	}
+
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	w.Header().Set("Accept-Ranges", "bytes")
+	if hasConditionalHeaders(r) {
+		http.ServeContent(w, r, "", modTime, content)
+		return
+	}
+	if !modTime.IsZero() {
+		w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+	writeShapedStream(w, r, http.StatusOK, content, size, shape)
+}
+
+// serveBytesEntity serves an in-memory body as a rangeable entity.
+func serveBytesEntity(w http.ResponseWriter, r *http.Request, body []byte, shape bodyShape) {
+	serveEntity(w, r, bytes.NewReader(body), int64(len(body)), bytesETag(body), startTime, shape)
+}
+
+// serveFileEntity serves a file from disk, using its size and modification time as validators.
+func serveFileEntity(w http.ResponseWriter, r *http.Request, path string, shape bodyShape) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("Failed to open response file: %v", err)
+		http.Error(w, "Response file not available", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.Error(w, "Response file not available", http.StatusInternalServerError)
+		return
+	}
+	if contentType := mime.TypeByExtension(filepath.Ext(path)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	etag := fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+	serveEntity(w, r, f, info.Size(), etag, info.ModTime(), shape)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func serveConditional(headers map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/download", nil)
+	req.Header.Set("X-Echo-Payload-Pattern", "seeded:3")
+	req.Header.Set("X-Echo-Response-Size", "1000")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestGeneratedPayloadRanges(t *testing.T) {
+	setupTest()
+	full := serveConditional(nil)
+	if full.Code != http.StatusOK || full.Header().Get("ETag") == "" || full.Header().Get("Accept-Ranges") != "bytes" {
+		t.Fatalf("expected a rangeable 200 with an ETag, got %d %v", full.Code, full.Header())
+	}
+
+	rr := serveConditional(map[string]string{"Range": "bytes=10-19"})
+	if rr.Code != http.StatusPartialContent || !bytes.Equal(rr.Body.Bytes(), full.Body.Bytes()[10:20]) {
+		t.Errorf("single range: got %d with %d bytes", rr.Code, rr.Body.Len())
+	}
+	if got := rr.Header().Get("Content-Range"); got != "bytes 10-19/1000" {
+		t.Errorf("unexpected Content-Range %q", got)
+	}
+
+	rr = serveConditional(map[string]string{"Range": "bytes=0-4,-5"})
+	mediaType, params, _ := mime.ParseMediaType(rr.Header().Get("Content-Type"))
+	if rr.Code != http.StatusPartialContent || mediaType != "multipart/byteranges" {
+		t.Fatalf("multi range: got %d %q", rr.Code, mediaType)
+	}
+	mr := multipart.NewReader(rr.Body, params["boundary"])
+	for _, want := range [][]byte{full.Body.Bytes()[:5], full.Body.Bytes()[995:]} {
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("reading part: %v", err)
+		}
+		if got, _ := io.ReadAll(part); !bytes.Equal(got, want) {
+			t.Errorf("part %q: unexpected bytes", part.Header.Get("Content-Range"))
+		}
+	}
+
+	rr = serveConditional(map[string]string{"Range": "bytes=5000-"})
+	if rr.Code != http.StatusRequestedRangeNotSatisfiable || rr.Header().Get("Content-Range") != "bytes */1000" {
+		t.Errorf("unsatisfiable range: got %d %q", rr.Code, rr.Header().Get("Content-Range"))
+	}
+
+	etag := full.Header().Get("ETag")
+	if rr = serveConditional(map[string]string{"Range": "bytes=0-9", "If-Range": etag}); rr.Code != http.StatusPartialContent {
+		t.Errorf("If-Range with current ETag should return 206, got %d", rr.Code)
+	}
+	if rr = serveConditional(map[string]string{"Range": "bytes=0-9", "If-Range": `"old"`}); rr.Code != http.StatusOK || rr.Body.Len() != 1000 {
+		t.Errorf("If-Range with stale ETag should return the full body, got %d", rr.Code)
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	setupTest()
+	saved := startTime
+	startTime = time.Now().Add(-time.Hour)
+	t.Cleanup(func() { startTime = saved })
+	full := serveConditional(nil)
+	etag := full.Header().Get("ETag")
+	lastModified := full.Header().Get("Last-Modified")
+	if lastModified == "" {
+		t.Fatal("expected a Last-Modified header")
+	}
+
+	if rr := serveConditional(map[string]string{"If-None-Match": etag}); rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("matching If-None-Match should return 304, got %d", rr.Code)
+	}
+	if rr := serveConditional(map[string]string{"If-None-Match": `"other"`}); rr.Code != http.StatusOK {
+		t.Errorf("non-matching If-None-Match should return 200, got %d", rr.Code)
+	}
+	if rr := serveConditional(map[string]string{"If-Modified-Since": lastModified}); rr.Code != http.StatusNotModified {
+		t.Errorf("If-Modified-Since at Last-Modified should return 304, got %d", rr.Code)
+	}
+	past := startTime.Add(-time.Hour).UTC().Format(http.TimeFormat)
+	if rr := serveConditional(map[string]string{"If-Modified-Since": past}); rr.Code != http.StatusOK {
+		t.Errorf("If-Modified-Since before Last-Modified should return 200, got %d", rr.Code)
+	}
+}
+
+func TestValidatorControls(t *testing.T) {
+	setupTest()
+	etag := serveConditional(nil).Header().Get("ETag")
+
+	rr := serveConditional(map[string]string{"X-Echo-Validators": "stale", "If-None-Match": `"anything"`})
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("stale validators should force 304, got %d", rr.Code)
+	}
+
+	rr = serveConditional(map[string]string{"X-Echo-Validators": "mismatch", "If-None-Match": etag})
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") == etag {
+		t.Errorf("mismatched validators should return 200 with a new ETag, got %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
+
+	rr = serveConditional(map[string]string{"X-Echo-Validators": "weak", "Range": "bytes=0-9", "If-Range": "W/" + etag})
+	if rr.Header().Get("ETag") != "W/"+etag || rr.Code != http.StatusOK {
+		t.Errorf("weak ETag should not satisfy If-Range, got %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
+
+	rr = serveConditional(map[string]string{"X-Echo-Validators": "none"})
+	if rr.Header().Get("ETag") != "" || rr.Header().Get("Last-Modified") != "" {
+		t.Errorf("expected no validators, got %v", rr.Header())
+	}
+}
+
+func TestScenarioFileRanges(t *testing.T) {
+	setupTest()
+	path := filepath.Join(t.TempDir(), "data.txt")
+	if err := os.WriteFile(path, []byte("0123456789abcdef"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	scenarios.Store("/file", []Response{{Status: 200, File: path}})
+
+	req, _ := http.NewRequest("GET", "/file", nil)
+	req.Header.Set("Range", "bytes=10-")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "abcdef" {
+		t.Errorf("file range: got %d %q", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("expected content type from extension, got %q", ct)
+	}
+
+	scenarios.Store("/inline", []Response{{Status: 200, Body: `{"ok":true}`}})
+	req, _ = http.NewRequest("GET", "/inline", nil)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	etag := rr.Header().Get("ETag")
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	if etag == "" || rr.Code != http.StatusNotModified {
+		t.Errorf("scenario body should revalidate with its ETag, got %d", rr.Code)
+	}
+}
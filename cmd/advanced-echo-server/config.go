@@ -26,19 +26,48 @@ type Config struct {
 	ScenarioFile       string
 	RateLimitRPS       float64
 	RateLimitBurst     int
+	VCRMode            string
+	VCRUpstream        string
+	VCRCassetteDir     string
+	VCRMatch           string
+	ProxyUpstream      string
+	ProxyFaultFile     string
+	MirrorTargets      string
+	MirrorPaths        string
+	MirrorPercent      int
+	MirrorHistory      bool
+	SlowReadFile       string
+	Seed               int64
+	RulesFile          string
+	ScheduleFile       string
+	GRPCPort           string
+	GraphQLSchema      string
+	TCPPort            string
+	TCPMode            string
+	UDPPort            string
+	TCPIdleTimeout     string
+	UDPWorkers         int
+	HTTP3Port          string
 }
 
 // Scenario defines a sequence of responses for an endpoint
 type Scenario struct {
 	Path      string     `yaml:"path" json:"path"`
+	Operation string     `yaml:"operation,omitempty" json:"operation,omitempty"`
 	Responses []Response `yaml:"responses" json:"responses"`
 }
 
 // Response defines a single response in a scenario
 type Response struct {
-	Status int    `yaml:"status" json:"status"`
-	Delay  string `yaml:"delay" json:"delay"`
-	Body   string `yaml:"body" json:"body"`
+	Status   int    `yaml:"status" json:"status"`
+	Delay    string `yaml:"delay" json:"delay"`
+	Body     string `yaml:"body" json:"body"`
+	File     string `yaml:"file,omitempty" json:"file,omitempty"`
+	Throttle string `yaml:"throttle,omitempty" json:"throttle,omitempty"`
+	TTFB     string `yaml:"ttfb,omitempty" json:"ttfb,omitempty"`
+	Duration string `yaml:"duration,omitempty" json:"duration,omitempty"`
+	Drip     string `yaml:"drip,omitempty" json:"drip,omitempty"`
+	Stream   string `yaml:"stream,omitempty" json:"stream,omitempty"`
 }
 
 // loadConfigFromEnv builds a Config from environment variables.
@@ -62,6 +91,28 @@ func loadConfigFromEnv() Config {
 		ScenarioFile:       getEnv("ECHO_SCENARIO_FILE", "scenarios.yaml"),
 		RateLimitRPS:       parseFloat64(getEnv("ECHO_RATE_LIMIT_RPS", "0")),
 		RateLimitBurst:     int(parseInt64(getEnv("ECHO_RATE_LIMIT_BURST", "0"))),
+		VCRMode:            getEnv("ECHO_VCR_MODE", ""),
+		VCRUpstream:        getEnv("ECHO_VCR_UPSTREAM", ""),
+		VCRCassetteDir:     getEnv("ECHO_VCR_CASSETTE_DIR", "cassettes"),
+		VCRMatch:           getEnv("ECHO_VCR_MATCH", "method,path,query"),
+		ProxyUpstream:      getEnv("ECHO_PROXY_UPSTREAM", ""),
+		ProxyFaultFile:     getEnv("ECHO_PROXY_FAULT_FILE", ""),
+		MirrorTargets:      getEnv("ECHO_MIRROR_TARGETS", ""),
+		MirrorPaths:        getEnv("ECHO_MIRROR_PATHS", ""),
+		MirrorPercent:      int(parseInt64(getEnv("ECHO_MIRROR_PERCENT", "100"))),
+		MirrorHistory:      getEnv("ECHO_MIRROR_HISTORY", "false") == "true",
+		SlowReadFile:       getEnv("ECHO_SLOW_READ_FILE", ""),
+		Seed:               parseInt64(getEnv("ECHO_SEED", "0")),
+		RulesFile:          getEnv("ECHO_RULES_FILE", ""),
+		ScheduleFile:       getEnv("ECHO_SCHEDULE_FILE", ""),
+		GRPCPort:           getEnv("ECHO_GRPC_PORT", ""),
+		GraphQLSchema:      getEnv("ECHO_GRAPHQL_SCHEMA", ""),
+		TCPPort:            getEnv("ECHO_TCP_PORT", ""),
+		TCPMode:            getEnv("ECHO_TCP_MODE", "bytes"),
+		UDPPort:            getEnv("ECHO_UDP_PORT", ""),
+		TCPIdleTimeout:     getEnv("ECHO_TCP_IDLE_TIMEOUT", "5m"),
+		UDPWorkers:         int(parseInt64(getEnv("ECHO_UDP_WORKERS", "64"))),
+		HTTP3Port:          getEnv("ECHO_HTTP3_PORT", ""),
 	}
 }
 
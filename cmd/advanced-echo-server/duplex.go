@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// streamingPaths are long-lived endpoints whose bodies must never be buffered by middleware.
+var streamingPaths = []string{"/sse", "/duplex"}
+
+func isStreamingPath(path string) bool {
+	return slices.Contains(streamingPaths, path)
+}
+
+// duplexTransforms rewrite each echoed chunk.
+var duplexTransforms = map[string]func([]byte) []byte{
+	"upper": bytes.ToUpper,
+	"lower": bytes.ToLower,
+	"reverse": func(b []byte) []byte {
+		out := slices.Clone(b)
+		slices.Reverse(out)
+		return out
+	},
+	"base64": func(b []byte) []byte { return []byte(base64.StdEncoding.EncodeToString(b) + "\n") },
+	"hex":    func(b []byte) []byte { return []byte(hex.EncodeToString(b) + "\n") },
+}
+
+// duplexHandler echoes each request chunk back as soon as it arrives, so clients
+// can read responses while still sending, as in gRPC bidirectional streaming. It
+// works over HTTP/2 (h2c or TLS) and, where the client allows it, HTTP/1.1.
+// X-Echo-Chunk-Delay / ECHO_CHUNK_DELAY pauses before each echoed chunk and
+// X-Echo-Transform / ECHO_TRANSFORM rewrites it (upper, lower, reverse, base64, hex).
+func duplexHandler(w http.ResponseWriter, r *http.Request) {
+	var delay time.Duration
+	if d := getHeaderOrEnv(r, "X-Echo-Chunk-Delay", "ECHO_CHUNK_DELAY"); d != "" {
+		parsed := parseDurationValue(d)
+		if parsed <= 0 {
+			http.Error(w, "Invalid chunk delay", http.StatusBadRequest)
+			return
+		}
+		delay = min(parsed, 300*time.Second)
+	}
+	var transform func([]byte) []byte
+	if name := strings.ToLower(getHeaderOrEnv(r, "X-Echo-Transform", "ECHO_TRANSFORM")); name != "" && name != "none" {
+		if transform = duplexTransforms[name]; transform == nil {
+			http.Error(w, "Unknown transform "+name, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// HTTP/1.1 normally finishes reading the request before responding; HTTP/2 is
+	// always full duplex, so an error here only means the protocol needs no help
+	rc := http.NewResponseController(w)
+	rc.EnableFullDuplex()
+	// Sessions last as long as the client keeps sending, so the server-wide read
+	// and write timeouts must not cut them off
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Echo-Duplex", r.Proto)
+	w.Header().Set("Trailer", "X-Echo-Chunks, X-Echo-Bytes")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Printf("Duplex streaming not supported for %s: %v", r.RemoteAddr, err)
+		return
+	}
+	log.Printf("Duplex stream opened for %s over %s", r.RemoteAddr, r.Proto)
+
+	chunks, total := 0, 0
+	buf := make([]byte, streamChunk)
+	for {
+		n, err := r.Body.Read(buf)
+		if n > 0 {
+			if !sleepContext(r.Context(), delay) {
+				return
+			}
+			chunk := buf[:n]
+			if transform != nil {
+				chunk = transform(chunk)
+			}
+			if _, werr := w.Write(chunk); werr != nil {
+				log.Printf("Duplex write error for %s: %v", r.RemoteAddr, werr)
+				return
+			}
+			rc.Flush()
+			chunks++
+			total += n
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			log.Printf("Duplex read error for %s: %v", r.RemoteAddr, err)
+			return
+		}
+	}
+	w.Header().Set("X-Echo-Chunks", strconv.Itoa(chunks))
+	w.Header().Set("X-Echo-Bytes", strconv.Itoa(total))
+	log.Printf("Duplex stream closed for %s: %d chunks, %d bytes", r.RemoteAddr, chunks, total)
+}
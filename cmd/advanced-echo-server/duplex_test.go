@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// duplexRoundTrips sends each message on an open request stream and reads its
+// echo before sending the next, which only succeeds over a full-duplex stream.
+func duplexRoundTrips(t *testing.T, client *http.Client, url string, headers map[string]string, messages []string) (*http.Response, []string) {
+	t.Helper()
+	pr, pw := io.Pipe()
+	req, _ := http.NewRequest("POST", url+"/duplex", pr)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("duplex request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var echoed []string
+	for _, msg := range messages {
+		if _, err := pw.Write([]byte(msg)); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		buf := make([]byte, len(msg))
+		if _, err := io.ReadFull(resp.Body, buf); err != nil {
+			t.Fatalf("reading echo of %q: %v", msg, err)
+		}
+		echoed = append(echoed, string(buf))
+	}
+	pw.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp, echoed
+}
+
+func TestDuplexEchoH2C(t *testing.T) {
+	setupTest()
+	server := httptest.NewServer(h2c.NewHandler(setupRoutes(), &http2.Server{}))
+	defer server.Close()
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+
+	resp, echoed := duplexRoundTrips(t, client, server.URL, map[string]string{"X-Echo-Transform": "upper"}, []string{"hello", "duplex", "world"})
+	if resp.ProtoMajor != 2 {
+		t.Errorf("expected HTTP/2, got %s", resp.Proto)
+	}
+	if !slices.Equal(echoed, []string{"HELLO", "DUPLEX", "WORLD"}) {
+		t.Errorf("unexpected echoes %v", echoed)
+	}
+	if resp.Trailer.Get("X-Echo-Chunks") != "3" || resp.Trailer.Get("X-Echo-Bytes") != "16" {
+		t.Errorf("unexpected trailers %v", resp.Trailer)
+	}
+}
+
+func TestDuplexEchoTLS(t *testing.T) {
+	setupTest()
+	server := httptest.NewUnstartedServer(setupRoutes())
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	start := time.Now()
+	resp, echoed := duplexRoundTrips(t, server.Client(), server.URL, map[string]string{"X-Echo-Chunk-Delay": "100ms", "X-Echo-Transform": "reverse"}, []string{"abc", "xyz"})
+	if resp.ProtoMajor != 2 {
+		t.Errorf("expected HTTP/2, got %s", resp.Proto)
+	}
+	if !slices.Equal(echoed, []string{"cba", "zyx"}) {
+		t.Errorf("unexpected echoes %v", echoed)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("two delayed chunks took only %v", elapsed)
+	}
+}
+
+func TestDuplexRejectsUnknownTransform(t *testing.T) {
+	setupTest()
+	req := httptest.NewRequest("POST", "/duplex", bytes.NewReader([]byte("x")))
+	req.Header.Set("X-Echo-Transform", "rot13")
+	rr := httptest.NewRecorder()
+	duplexHandler(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown transform, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/duplex", bytes.NewReader([]byte("x")))
+	req.Header.Set("X-Echo-Chunk-Delay", "soon")
+	rr = httptest.NewRecorder()
+	duplexHandler(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid chunk delay, got %d", rr.Code)
+	}
+}
+
+func TestDuplexOutlivesServerTimeouts(t *testing.T) {
+	setupTest()
+	server := httptest.NewUnstartedServer(setupRoutes())
+	server.Config.ReadTimeout = 100 * time.Millisecond
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	resp, echoed := duplexRoundTrips(t, server.Client(), server.URL, map[string]string{"X-Echo-Chunk-Delay": "150"}, []string{"one", "two", "three"})
+	if !slices.Equal(echoed, []string{"one", "two", "three"}) {
+		t.Errorf("unexpected echoes %v", echoed)
+	}
+	if resp.Trailer.Get("X-Echo-Chunks") != "3" {
+		t.Errorf("the stream should end cleanly, got trailers %v", resp.Trailer)
+	}
+}
@@ -2,59 +2,174 @@ package main
 
 import (
 	"bytes"
-	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"math"
-	mrand "math/rand"
 	"net/http"
 	"os"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// Main Echo Handler (moved from main.go)
-func echoHandler(w http.ResponseWriter, r *http.Request) {
-	// Increment request counter
+// echoRequest is a request that has been through beginEcho.
+type echoRequest struct {
+	r     *http.Request // carries the request's seed and route rule
+	body  []byte
+	count uint64
+	delay time.Duration // delay injected by applyDelays
+}
+
+// beginEcho runs the setup shared by the echo, gRPC, gRPC-Web/Connect, GraphQL and
+// JSON-RPC handlers: it seeds per-request randomness so chaos decisions can be
+// replayed, applies the first matching route rule beneath header controls, counts
+// the request, reads and records the body, and applies the delay controls.
+//
+// read reads the body and returns false once it has answered the request itself.
+// beforeDelay, if set, runs just before the delays. beginEcho returns false when
+// the request has already been answered.
+func beginEcho(w http.ResponseWriter, r *http.Request, read func(http.ResponseWriter, *http.Request) ([]byte, bool), beforeDelay func(http.ResponseWriter, *http.Request)) (echoRequest, bool) {
+	r = withRequestRand(w, r)
+	r = withRouteRule(w, r)
+
 	counterMutex.Lock()
 	requestCounter++
 	currentCount := requestCounter
 	counterMutex.Unlock()
+	w.Header().Set("X-Echo-Request-Count", strconv.FormatUint(currentCount, 10))
 
-	// Read config fields with lock
+	body, ok := read(w, r)
+	if !ok {
+		return echoRequest{}, false
+	}
 	configLock.RLock()
-	maxBodySize := config.MaxBodySize
 	historySize := config.HistorySize
 	configLock.RUnlock()
-
-	// Record request for history
-	var body []byte
-	var err error
-	if r.Body != nil {
-		body, err = io.ReadAll(io.LimitReader(r.Body, maxBodySize))
-		if err != nil {
-			http.Error(w, "Error reading body: "+err.Error(), http.StatusBadRequest)
-			return
-		}
-		r.Body = io.NopCloser(bytes.NewReader(body))
-	} else {
-		body = []byte{}
-	}
 	if historySize > 0 {
 		recordRequest(r, body)
 	}
+	if beforeDelay != nil {
+		beforeDelay(w, r)
+	}
 
-	// Apply delays from headers or environment variables
+	delayStart := time.Now()
 	applyDelays(r)
+	return echoRequest{r: r, body: body, count: currentCount, delay: time.Since(delayStart)}, true
+}
+
+// readBody reads the request body up to MAX_BODY_SIZE.
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	configLock.RLock()
+	maxBodySize := config.MaxBodySize
+	configLock.RUnlock()
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "Error reading body: "+err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
+
+// readEchoBody reads the body for the echo handler: it answers Expect:
+// 100-continue first, paces the read as the read controls ask and decodes any
+// Content-Encoding. The request body is replaced by the decoded one.
+func readEchoBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	configLock.RLock()
+	maxBodySize := config.MaxBodySize
+	configLock.RUnlock()
+
+	// Accept, reject or hold back Expect: 100-continue before touching the body
+	if processExpect(w, r) {
+		return nil, false
+	}
+	if r.Body == nil {
+		return []byte{}, true
+	}
+
+	var src io.Reader = r.Body
+	readShape := readShapeFor(r)
+	if readShape.active() {
+		src = newSlowReader(r.Context(), r.Body, readShape)
+	}
+	body, err := io.ReadAll(io.LimitReader(src, maxBodySize))
+	if readShape.active() {
+		w.Header().Set("X-Echo-Read-Bytes", strconv.Itoa(len(body)))
+	}
+	if err != nil {
+		http.Error(w, "Error reading body: "+err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if encoding := r.Header.Get("Content-Encoding"); encoding != "" {
+		body, err = decodeRequestBody(r, body, maxBodySize)
+		if errors.Is(err, errUnsupportedEncoding) {
+			http.Error(w, "Error decoding body: "+err.Error(), http.StatusUnsupportedMediaType)
+			return nil, false
+		} else if err != nil {
+			http.Error(w, "Error decoding body: "+err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+		w.Header().Set("X-Echo-Request-Decoded", encoding)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true
+}
+
+// Main Echo Handler (moved from main.go)
+func echoHandler(w http.ResponseWriter, r *http.Request) {
+	// Send 1xx responses such as 103 Early Hints ahead of the delays and final status
+	er, ok := beginEcho(w, r, readEchoBody, processInformational)
+	if !ok {
+		return
+	}
+	r, body := er.r, er.body
+
+	// Declare response trailers, filled in after the body is written
+	defer processTrailers(w, r)()
+
+	// Apply open outage windows, scaling the delay injected so far
+	if processSchedules(w, r, er.delay) {
+		return
+	}
+
+	// Inject connection-level faults
+	if processConnectionFault(w, r, body) {
+		return
+	}
+
+	// Emit malformed or protocol-violating responses
+	if processMalform(w, r, body) {
+		return
+	}
+
+	// Fail the first attempts of each logical request
+	if processFailTimes(w, r) {
+		return
+	}
 
 	// Process testing features
 	if processTestingFeatures(w, r, body) {
 		return
 	}
 
+	// Start a redirect chain
+	if processRedirect(w, r) {
+		return
+	}
+
+	// Record, play back or pass through upstream traffic
+	if processVCR(w, r, body) {
+		return
+	}
+
+	// Forward to the proxy upstream with fault injection
+	if processProxy(w, r, body) {
+		return
+	}
+
 	// Fall back to scenario responses
 	if processScenario(w, r) {
 		return
@@ -62,47 +177,42 @@ func echoHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Echo back custom headers
 	echoCustomHeaders(w, r)
-	w.Header().Set("X-Echo-Request-Count", strconv.FormatUint(currentCount, 10))
 
 	// Set dynamic response headers from environment variables
 	setEnvHeaders(w)
 
+	// Stream generated payloads without buffering them
+	if payload, contentType, ok := generatedPayloadFor(r); ok {
+		writeGeneratedPayload(w, r, payload, contentType)
+		return
+	}
+
 	// Handle body based on request type
 	var responseBody []byte
 	if r.Method == "GET" && len(body) == 0 {
 		responseBody = []byte(echoRequestInfo(r))
 		w.Header().Set("Content-Type", "text/plain")
 	} else {
-		// Dynamic response generation
-		if sizeHeader := r.Header.Get("X-Echo-Response-Size"); sizeHeader != "" {
-			size, err := strconv.Atoi(sizeHeader)
-			if err == nil && size > 0 {
-				responseBody = make([]byte, size)
-				mrand.Read(responseBody)
-				w.Header().Set("Content-Type", "application/octet-stream")
-			}
-		} else {
-			responseBody = body
-			setResponseContentType(w, r)
-		}
+		responseBody = body
+		setResponseContentType(w, r)
 	}
 
-	// Handle GZIP compression
-	if compressHeader := r.Header.Get("X-Echo-Compress"); strings.ToLower(compressHeader) == "gzip" {
-		var buf bytes.Buffer
-		gz := gzip.NewWriter(&buf)
-		gz.Write(responseBody)
-		gz.Close()
-		responseBody = buf.Bytes()
-		w.Header().Set("Content-Encoding", "gzip")
+	// Send the body as a paced chunked stream
+	if stream, ok := streamSpecFor(r, Response{}); ok {
+		writeStream(w, r, http.StatusOK, responseBody, stream)
+		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(responseBody)
+	// Compress as negotiated or forced
+	responseBody = compressResponse(w, r, responseBody)
+
+	writeShapedBody(w, r, http.StatusOK, responseBody, bodyShapeFor(r, Response{}))
 }
 
 // Process testing features (moved from main.go)
 func processTestingFeatures(w http.ResponseWriter, r *http.Request, body []byte) bool {
+	rnd := requestRand(r)
+
 	// Force HTTP status code
 	statusStr := getHeaderOrEnv(r, "X-Echo-Status", "ECHO_STATUS")
 	if statusStr != "" {
@@ -164,7 +274,7 @@ func processTestingFeatures(w http.ResponseWriter, r *http.Request, body []byte)
 			return true
 		case "random":
 			errors := []int{500, 502, 503, 504, 429}
-			status := errors[rng.Intn(len(errors))]
+			status := errors[rnd.Intn(len(errors))]
 			w.Header().Set("Content-Type", "text/plain")
 			w.WriteHeader(status)
 			w.Write([]byte(fmt.Sprintf("Random simulated error: %d", status)))
@@ -177,9 +287,8 @@ func processTestingFeatures(w http.ResponseWriter, r *http.Request, body []byte)
 	chaosStr := getHeaderOrEnv(r, "X-Echo-Chaos", "ECHO_CHAOS")
 	if chaosStr != "" {
 		if rate, err := strconv.Atoi(chaosStr); err == nil && rate > 0 && rate <= 100 {
-			if rng.Intn(100) < rate {
-				errors := []int{500, 502, 503, 504, 408, 429}
-				status := errors[rng.Intn(len(errors))]
+			if rnd.Intn(100) < rate {
+				status := pickChaosStatus(getHeaderOrEnv(r, "X-Echo-Chaos-Errors", "ECHO_CHAOS_ERRORS"), rnd)
 				log.Printf("Chaos: Injecting %d error for %s (%d%% rate)", status, r.RemoteAddr, rate)
 				w.Header().Set("Content-Type", "text/plain")
 				w.WriteHeader(status)
@@ -195,6 +304,18 @@ func processTestingFeatures(w http.ResponseWriter, r *http.Request, body []byte)
 
 // Apply various delay patterns (moved from main.go)
 func applyDelays(r *http.Request) {
+	rnd := requestRand(r)
+
+	// A route rule's latency ranks between request headers and environment
+	// variables, like every other rule control, so it wins over ECHO_* delays
+	// that the chain below would otherwise evaluate first
+	if latency := ruleControl(r, "X-Echo-Latency-Dist"); latency != "" && !slices.ContainsFunc(delayHeaders, func(h string) bool {
+		return r.Header.Get(h) != ""
+	}) {
+		sleepLatencyDist(rnd, latency)
+		return
+	}
+
 	// Simple delay
 	delayStr := getHeaderOrEnv(r, "X-Echo-Delay", "ECHO_DELAY")
 	if delayStr != "" {
@@ -217,7 +338,7 @@ func applyDelays(r *http.Request) {
 		if len(parts) == 2 {
 			if base, err1 := strconv.Atoi(parts[0]); err1 == nil {
 				if variance, err2 := strconv.Atoi(parts[1]); err2 == nil && variance >= 0 {
-					jitter := rng.Intn(variance*2) - variance
+					jitter := rnd.Intn(variance*2) - variance
 					totalDelay := base + jitter
 					if totalDelay < 0 {
 						totalDelay = 0
@@ -243,7 +364,7 @@ func applyDelays(r *http.Request) {
 					if max > 300000 {
 						max = 300000
 					}
-					delay := min + rng.Intn(max-min+1)
+					delay := min + rnd.Intn(max-min+1)
 					log.Printf("Random delay: %dms (range: %d-%d)", delay, min, max)
 					time.Sleep(time.Duration(delay) * time.Millisecond)
 					return
@@ -264,7 +385,7 @@ func applyDelays(r *http.Request) {
 						exponentialDelay = 300000
 					}
 					jitter := int(float64(exponentialDelay) * 0.25)
-					finalDelay := exponentialDelay + rng.Intn(jitter*2) - jitter
+					finalDelay := exponentialDelay + rnd.Intn(jitter*2) - jitter
 					if finalDelay < 0 {
 						finalDelay = 0
 					}
@@ -288,7 +409,7 @@ func applyDelays(r *http.Request) {
 				min, _ := strconv.Atoi(minStr)
 				max, _ := strconv.Atoi(maxStr)
 				if max >= min {
-					delay := min + rng.Intn(max-min+1)
+					delay := min + rnd.Intn(max-min+1)
 					log.Printf("Latency injection: %dms (range: %d-%d)", delay, min, max)
 					time.Sleep(time.Duration(delay) * time.Millisecond)
 					return
@@ -307,6 +428,29 @@ func applyDelays(r *http.Request) {
 			}
 		}
 	}
+
+	// Statistical latency distribution
+	if distStr := getHeaderOrEnv(r, "X-Echo-Latency-Dist", "ECHO_LATENCY_DIST"); distStr != "" {
+		sleepLatencyDist(rnd, distStr)
+	}
+}
+
+// delayHeaders are the request headers that select a delay in applyDelays.
+var delayHeaders = []string{
+	"X-Echo-Delay", "X-Echo-Jitter", "X-Echo-Random-Delay",
+	"X-Echo-Exponential", "X-Echo-Latency", "X-Echo-Latency-Dist",
+}
+
+// sleepLatencyDist sleeps for a sample of the latency distribution spec.
+func sleepLatencyDist(rnd *lockedRand, spec string) {
+	dist, err := parseLatencyDist(spec)
+	if err != nil {
+		log.Printf("Invalid latency distribution %q: %v", spec, err)
+		return
+	}
+	delay := dist.sample(rnd)
+	log.Printf("Distribution delay: %v (%s)", delay, dist.kind)
+	time.Sleep(delay)
 }
 
 // Echo back custom headers (moved from main.go)
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Connection-level faults selectable with X-Echo-Fault / ECHO_FAULT
+const (
+	faultReset      = "reset"       // TCP RST instead of a response
+	faultClose      = "close"       // close partway through the header block
+	faultCloseAfter = "close-after" // full headers, then close after N body bytes
+	faultHang       = "hang"        // full headers, then never send the body
+	faultEmpty      = "empty"       // close without sending a single byte
+)
+
+// hijackConn takes over the raw connection when the transport allows it (HTTP/1.x).
+func hijackConn(w http.ResponseWriter) (net.Conn, *bufio.ReadWriter, bool) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, false
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, false
+	}
+	return conn, buf, true
+}
+
+// resetConn closes conn with SO_LINGER=0 so the peer receives a TCP RST instead of a FIN.
+func resetConn(conn net.Conn) {
+	raw := conn
+	if tlsConn, ok := raw.(*tls.Conn); ok {
+		raw = tlsConn.NetConn()
+	}
+	if tcpConn, ok := raw.(*net.TCPConn); ok {
+		tcpConn.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// dropConnection aborts the exchange without a response. HTTP/1 connections are
+// hijacked and closed; otherwise the handler is aborted so the server resets the stream.
+func dropConnection(w http.ResponseWriter) {
+	if conn, _, ok := hijackConn(w); ok {
+		conn.Close()
+		return
+	}
+	panic(http.ErrAbortHandler)
+}
+
+// parseFault splits a fault spec such as "close-after:128" into its kind and argument.
+func parseFault(spec string) (string, int) {
+	kind, arg, _ := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), ":")
+	n, _ := strconv.Atoi(arg)
+	return kind, n
+}
+
+// processConnectionFault produces transport-level failures that a well-formed
+// HTTP response cannot express. It returns true when the request was consumed.
+func processConnectionFault(w http.ResponseWriter, r *http.Request, body []byte) bool {
+	spec := getHeaderOrEnv(r, "X-Echo-Fault", "ECHO_FAULT")
+	if spec == "" {
+		return false
+	}
+	kind, n := parseFault(spec)
+
+	switch kind {
+	case faultReset:
+		log.Printf("Fault: resetting connection for %s", r.RemoteAddr)
+		if conn, _, ok := hijackConn(w); ok {
+			resetConn(conn)
+		} else {
+			chaosErrors.WithLabelValues("fault_reset").Inc()
+			panic(http.ErrAbortHandler)
+		}
+
+	case faultEmpty:
+		log.Printf("Fault: closing connection without a reply for %s", r.RemoteAddr)
+		chaosErrors.WithLabelValues("fault_empty").Inc()
+		dropConnection(w)
+		return true
+
+	case faultClose:
+		log.Printf("Fault: closing connection mid-headers for %s", r.RemoteAddr)
+		conn, buf, ok := hijackConn(w)
+		if !ok {
+			chaosErrors.WithLabelValues("fault_close").Inc()
+			panic(http.ErrAbortHandler)
+		}
+		buf.WriteString("HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n")
+		buf.Flush()
+		conn.Close()
+
+	case faultCloseAfter:
+		if n < 0 {
+			return false
+		}
+		log.Printf("Fault: closing connection after %d body bytes for %s", n, r.RemoteAddr)
+		payload := faultPayload(r, body, n+1)
+		conn, buf, ok := hijackConn(w)
+		if !ok {
+			// Declare the full length and abort once the partial body is flushed
+			chaosErrors.WithLabelValues("fault_close_after").Inc()
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+			w.WriteHeader(http.StatusOK)
+			w.Write(payload[:n])
+			if flusher, ok := w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+			panic(http.ErrAbortHandler)
+		}
+		fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\nContent-Length: %d\r\n\r\n", len(payload))
+		buf.Write(payload[:n])
+		buf.Flush()
+		conn.Close()
+
+	case faultHang:
+		log.Printf("Fault: sending headers then hanging for %s", r.RemoteAddr)
+		chaosErrors.WithLabelValues("fault_hang").Inc()
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		<-r.Context().Done()
+		return true
+
+	default:
+		return false
+	}
+
+	chaosErrors.WithLabelValues("fault_" + strings.ReplaceAll(kind, "-", "_")).Inc()
+	return true
+}
+
+// faultPayload returns the echo body padded to at least size bytes.
+func faultPayload(r *http.Request, body []byte, size int) []byte {
+	payload := body
+	if r.Method == "GET" && len(body) == 0 {
+		payload = []byte(echoRequestInfo(r))
+	}
+	if len(payload) < size {
+		payload = append(append([]byte(nil), payload...), []byte(strings.Repeat(".", size-len(payload)))...)
+	}
+	return payload
+}
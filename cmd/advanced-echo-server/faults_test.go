@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rawFaultRequest sends a request with the given fault over a raw TCP connection
+// and returns everything the server wrote before closing.
+func rawFaultRequest(t *testing.T, serverURL, fault string) (string, error) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(serverURL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	io.WriteString(conn, "GET /fault HTTP/1.1\r\nHost: test\r\nX-Echo-Fault: "+fault+"\r\n\r\n")
+	data, err := io.ReadAll(bufio.NewReader(conn))
+	return string(data), err
+}
+
+func TestConnectionFaultEmptyAndClose(t *testing.T) {
+	setupTest()
+	server := newTrackedServer(t, http.HandlerFunc(echoHandler))
+	defer server.Close()
+
+	data, err := rawFaultRequest(t, server.URL, "empty")
+	if err != nil || data != "" {
+		t.Errorf("empty: expected clean close with no bytes, got %q err=%v", data, err)
+	}
+
+	data, _ = rawFaultRequest(t, server.URL, "close")
+	if !strings.HasPrefix(data, "HTTP/1.1 200 OK\r\n") || strings.Contains(data, "\r\n\r\n") {
+		t.Errorf("close: expected incomplete header block, got %q", data)
+	}
+}
+
+func TestConnectionFaultReset(t *testing.T) {
+	setupTest()
+	server := newTrackedServer(t, http.HandlerFunc(echoHandler))
+	defer server.Close()
+
+	data, err := rawFaultRequest(t, server.URL, "reset")
+	if data != "" {
+		t.Errorf("reset: expected no response bytes, got %q", data)
+	}
+	if err == nil || !strings.Contains(err.Error(), "reset") {
+		t.Errorf("reset: expected connection reset error, got %v", err)
+	}
+}
+
+func TestConnectionFaultCloseAfterBytes(t *testing.T) {
+	setupTest()
+	server := newTrackedServer(t, http.HandlerFunc(echoHandler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL, strings.NewReader("abcdefghij"))
+	req.Header.Set("X-Echo-Fault", "close-after:4")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("headers should arrive intact: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.ContentLength != 10 {
+		t.Errorf("expected declared length 10, got %d", resp.ContentLength)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != io.ErrUnexpectedEOF || string(body) != "abcd" {
+		t.Errorf("expected 4 bytes then unexpected EOF, got %q err=%v", body, err)
+	}
+}
+
+func TestConnectionFaultHang(t *testing.T) {
+	setupTest()
+	server := newTrackedServer(t, http.HandlerFunc(echoHandler))
+	defer server.Close()
+
+	client := &http.Client{Timeout: 200 * time.Millisecond}
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("X-Echo-Fault", "hang")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("headers should arrive before the hang: %v", err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Error("expected body read to time out")
+	}
+}
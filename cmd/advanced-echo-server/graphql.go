@@ -0,0 +1,582 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"github.com/vektah/gqlparser/v2/parser"
+	"github.com/vektah/gqlparser/v2/validator"
+)
+
+var (
+	graphqlSchema      *ast.Schema
+	graphqlSchemaMutex sync.RWMutex
+)
+
+// graphqlListSize is the length of generated lists when the field has no
+// first/last/limit argument.
+const graphqlListSize = 2
+
+// loadGraphQLSchema reads the SDL file that /graphql validates and answers against.
+func loadGraphQLSchema(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Failed to read GraphQL schema: %v", err)
+		return
+	}
+	schema, err := gqlparser.LoadSchema(&ast.Source{Name: path, Input: string(data)})
+	if err != nil {
+		log.Printf("Failed to parse GraphQL schema: %v", err)
+		return
+	}
+	graphqlSchemaMutex.Lock()
+	graphqlSchema = schema
+	graphqlSchemaMutex.Unlock()
+}
+
+// graphqlParams is a GraphQL-over-HTTP request.
+type graphqlParams struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// readGraphQLParams decodes the request from the query string (GET), an
+// application/graphql body, or the usual JSON body.
+func readGraphQLParams(r *http.Request, body []byte) (graphqlParams, error) {
+	var params graphqlParams
+	switch {
+	case r.Method == http.MethodGet:
+		q := r.URL.Query()
+		params.Query, params.OperationName = q.Get("query"), q.Get("operationName")
+		if vars := q.Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &params.Variables); err != nil {
+				return params, fmt.Errorf("invalid variables: %v", err)
+			}
+		}
+	case strings.HasPrefix(r.Header.Get("Content-Type"), "application/graphql"):
+		params.Query = string(body)
+	default:
+		if err := json.Unmarshal(body, &params); err != nil {
+			return params, fmt.Errorf("invalid request body: %v", err)
+		}
+	}
+	if strings.TrimSpace(params.Query) == "" {
+		return params, fmt.Errorf("missing query")
+	}
+	return params, nil
+}
+
+// parseGraphQL parses the query and, when a schema is loaded, validates it.
+func parseGraphQL(schema *ast.Schema, query string) (*ast.QueryDocument, gqlerror.List) {
+	if schema != nil {
+		return gqlparser.LoadQueryWithRules(schema, query, nil)
+	}
+	doc, err := parser.ParseQuery(&ast.Source{Input: query})
+	if err != nil {
+		return nil, gqlerror.List{gqlerror.WrapIfUnwrapped(err)}
+	}
+	return doc, nil
+}
+
+// selectOperation picks the operation to run, as named by operationName.
+func selectOperation(doc *ast.QueryDocument, name string) (*ast.OperationDefinition, error) {
+	if name != "" {
+		if op := doc.Operations.ForName(name); op != nil {
+			return op, nil
+		}
+		return nil, fmt.Errorf("unknown operation %q", name)
+	}
+	if len(doc.Operations) != 1 {
+		return nil, fmt.Errorf("operationName is required when the document has %d operations", len(doc.Operations))
+	}
+	return doc.Operations[0], nil
+}
+
+// graphqlObject is a JSON object that keeps the order of the selection set.
+type graphqlObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *graphqlObject) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *graphqlObject) MarshalJSON() ([]byte, error) {
+	out := []byte{'{'}
+	for i, key := range o.keys {
+		if i > 0 {
+			out = append(out, ',')
+		}
+		k, _ := json.Marshal(key)
+		v, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		out = append(append(append(out, k...), ':'), v...)
+	}
+	return append(out, '}'), nil
+}
+
+// graphqlFault is an injected field error: a dotted path of response keys, where
+// "*" matches any single key, and the code reported in the error's extensions.
+type graphqlFault struct {
+	path []string
+	code string
+}
+
+// parseGraphQLFaults parses "user.email,orders.*:FORBIDDEN". Codes default to
+// INTERNAL_SERVER_ERROR.
+func parseGraphQLFaults(spec string) []graphqlFault {
+	var faults []graphqlFault
+	for _, part := range strings.Split(spec, ",") {
+		path, code, _ := strings.Cut(strings.TrimSpace(part), ":")
+		if path == "" {
+			continue
+		}
+		if code == "" {
+			code = "INTERNAL_SERVER_ERROR"
+		}
+		faults = append(faults, graphqlFault{path: strings.Split(path, "."), code: code})
+	}
+	return faults
+}
+
+// matches reports whether the fault applies to a field. List indices are ignored,
+// so "orders.total" fails the total of every order.
+func (f graphqlFault) matches(path ast.Path) bool {
+	var keys []string
+	for _, elem := range path {
+		if name, ok := elem.(ast.PathName); ok {
+			keys = append(keys, string(name))
+		}
+	}
+	if len(keys) != len(f.path) {
+		return false
+	}
+	for i, key := range keys {
+		if f.path[i] != "*" && f.path[i] != key {
+			return false
+		}
+	}
+	return true
+}
+
+// graphqlResolver generates data for an operation from the schema, or from the
+// shape of the query alone when no schema is loaded.
+type graphqlResolver struct {
+	schema *ast.Schema
+	doc    *ast.QueryDocument
+	vars   map[string]interface{}
+	rnd    *lockedRand
+	faults []graphqlFault
+	errors gqlerror.List
+}
+
+// execute resolves the operation. Data is nil when an error reached a non-null
+// root field.
+func (g *graphqlResolver) execute(op *ast.OperationDefinition) interface{} {
+	var root *ast.Definition
+	if g.schema != nil {
+		switch op.Operation {
+		case ast.Mutation:
+			root = g.schema.Mutation
+		case ast.Subscription:
+			root = g.schema.Subscription
+		default:
+			root = g.schema.Query
+		}
+	}
+	typeName := strings.ToUpper(string(op.Operation[:1])) + string(op.Operation[1:])
+	data, ok := g.object(root, typeName, op.SelectionSet, nil)
+	if !ok {
+		return nil
+	}
+	return data
+}
+
+// object resolves a selection set against an object type. It returns false when a
+// non-null field came back null, which nulls the object itself.
+func (g *graphqlResolver) object(def *ast.Definition, typeName string, set ast.SelectionSet, path ast.Path) (*graphqlObject, bool) {
+	if def != nil {
+		typeName = def.Name
+	}
+	keys, fields := g.collectFields(def, set, nil, map[string][]*ast.Field{})
+	obj := &graphqlObject{values: map[string]interface{}{}}
+	for _, key := range keys {
+		field := fields[key][0]
+		fieldPath := append(path[:len(path):len(path)], ast.PathName(key))
+		if field.Name == "__typename" {
+			obj.set(key, typeName)
+			continue
+		}
+		var fieldType *ast.Type
+		if def != nil {
+			if fieldDef := def.Fields.ForName(field.Name); fieldDef != nil {
+				fieldType = fieldDef.Type
+			}
+		}
+		if code, failed := g.fault(fieldPath); failed {
+			g.errors = append(g.errors, &gqlerror.Error{
+				Message:    fmt.Sprintf("injected error resolving %s", field.Name),
+				Path:       fieldPath,
+				Locations:  []gqlerror.Location{{Line: field.Position.Line, Column: field.Position.Column}},
+				Extensions: map[string]interface{}{"code": code},
+			})
+			chaosErrors.WithLabelValues("graphql_error").Inc()
+			if fieldType != nil && fieldType.NonNull {
+				return nil, false
+			}
+			obj.set(key, nil)
+			continue
+		}
+		var merged ast.SelectionSet
+		for _, f := range fields[key] {
+			merged = append(merged, f.SelectionSet...)
+		}
+		value, ok := g.value(fieldType, field, merged, fieldPath)
+		if !ok {
+			return nil, false
+		}
+		obj.set(key, value)
+	}
+	return obj, true
+}
+
+// value completes a field of type t. It returns false when t is non-null and the
+// value is null.
+func (g *graphqlResolver) value(t *ast.Type, field *ast.Field, set ast.SelectionSet, path ast.Path) (interface{}, bool) {
+	var value interface{}
+	null := false
+	switch {
+	case t == nil:
+		// Without a schema, fields with a selection set are objects and the rest strings
+		if len(set) > 0 {
+			typeName := strings.ToUpper(field.Name[:1]) + field.Name[1:]
+			obj, ok := g.object(nil, typeName, set, path)
+			value, null = obj, !ok
+		} else {
+			value = g.scalar("String", field.Name)
+		}
+	case t.Elem != nil:
+		items := make([]interface{}, g.listSize(field))
+		for i := range items {
+			item, ok := g.value(t.Elem, field, set, append(path[:len(path):len(path)], ast.PathIndex(i)))
+			// Keep going so every failing item reports its error
+			null = null || !ok
+			items[i] = item
+		}
+		value = items
+	default:
+		value, null = g.named(g.schema.Types[t.NamedType], field, set, path)
+	}
+	if null {
+		return nil, t == nil || !t.NonNull
+	}
+	return value, true
+}
+
+// named completes a value of a named schema type. It reports true when the value
+// is null.
+func (g *graphqlResolver) named(def *ast.Definition, field *ast.Field, set ast.SelectionSet, path ast.Path) (interface{}, bool) {
+	if def == nil {
+		return nil, true
+	}
+	switch def.Kind {
+	case ast.Scalar:
+		return g.scalar(def.Name, field.Name), false
+	case ast.Enum:
+		if len(def.EnumValues) == 0 {
+			return nil, true
+		}
+		return def.EnumValues[g.rnd.Intn(len(def.EnumValues))].Name, false
+	case ast.Interface, ast.Union:
+		possible := g.schema.GetPossibleTypes(def)
+		if len(possible) == 0 {
+			return nil, true
+		}
+		def = possible[g.rnd.Intn(len(possible))]
+	}
+	obj, ok := g.object(def, def.Name, set, path)
+	return obj, !ok
+}
+
+// scalar generates a value for a built-in scalar; custom scalars get strings.
+func (g *graphqlResolver) scalar(typeName, fieldName string) interface{} {
+	switch typeName {
+	case "Int":
+		return g.rnd.Intn(1000)
+	case "Float":
+		return float64(g.rnd.Intn(100000)) / 100
+	case "Boolean":
+		return g.rnd.Intn(2) == 1
+	case "ID":
+		return strconv.Itoa(1 + g.rnd.Intn(99999))
+	default:
+		return fmt.Sprintf("%s-%04x", fieldName, g.rnd.Intn(0x10000))
+	}
+}
+
+// listSize honors the usual paging arguments, capped at 100 items.
+func (g *graphqlResolver) listSize(field *ast.Field) int {
+	for _, name := range []string{"first", "last", "limit"} {
+		arg := field.Arguments.ForName(name)
+		if arg == nil {
+			continue
+		}
+		value, err := arg.Value.Value(g.vars)
+		if err != nil {
+			continue
+		}
+		var n int
+		switch v := value.(type) {
+		case int64:
+			n = int(v)
+		case float64:
+			n = int(v)
+		case json.Number:
+			i, _ := v.Int64()
+			n = int(i)
+		default:
+			continue
+		}
+		return max(0, min(n, 100))
+	}
+	return graphqlListSize
+}
+
+func (g *graphqlResolver) fault(path ast.Path) (string, bool) {
+	for _, f := range g.faults {
+		if f.matches(path) {
+			return f.code, true
+		}
+	}
+	return "", false
+}
+
+// collectFields flattens fragments that apply to def into fields grouped by
+// response key, in selection order.
+func (g *graphqlResolver) collectFields(def *ast.Definition, set ast.SelectionSet, keys []string, fields map[string][]*ast.Field) ([]string, map[string][]*ast.Field) {
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			if !g.included(sel.Directives) {
+				continue
+			}
+			key := sel.Alias
+			if key == "" {
+				key = sel.Name
+			}
+			if _, seen := fields[key]; !seen {
+				keys = append(keys, key)
+			}
+			fields[key] = append(fields[key], sel)
+		case *ast.InlineFragment:
+			if g.included(sel.Directives) && g.applies(sel.TypeCondition, def) {
+				keys, fields = g.collectFields(def, sel.SelectionSet, keys, fields)
+			}
+		case *ast.FragmentSpread:
+			frag := g.doc.Fragments.ForName(sel.Name)
+			if frag != nil && g.included(sel.Directives) && g.applies(frag.TypeCondition, def) {
+				keys, fields = g.collectFields(def, frag.SelectionSet, keys, fields)
+			}
+		}
+	}
+	return keys, fields
+}
+
+// applies reports whether a fragment's type condition covers def. Without a
+// schema every fragment applies.
+func (g *graphqlResolver) applies(condition string, def *ast.Definition) bool {
+	if condition == "" || def == nil || condition == def.Name {
+		return true
+	}
+	if cond := g.schema.Types[condition]; cond != nil && cond.IsAbstractType() {
+		for _, possible := range g.schema.GetPossibleTypes(cond) {
+			if possible.Name == def.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// included evaluates @skip and @include.
+func (g *graphqlResolver) included(directives ast.DirectiveList) bool {
+	check := func(name string) (bool, bool) {
+		d := directives.ForName(name)
+		if d == nil {
+			return false, false
+		}
+		arg := d.Arguments.ForName("if")
+		if arg == nil {
+			return false, false
+		}
+		value, err := arg.Value.Value(g.vars)
+		b, ok := value.(bool)
+		return b, err == nil && ok
+	}
+	if skip, ok := check("skip"); ok && skip {
+		return false
+	}
+	if include, ok := check("include"); ok && !include {
+		return false
+	}
+	return true
+}
+
+// selectedFields lists the dotted field paths an operation selects, fragments
+// included, in the order they first appear.
+func selectedFields(doc *ast.QueryDocument, set ast.SelectionSet, prefix string, seen map[string]bool, out []string) []string {
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			path := prefix + sel.Name
+			if !seen[path] {
+				seen[path] = true
+				out = append(out, path)
+			}
+			out = selectedFields(doc, sel.SelectionSet, path+".", seen, out)
+		case *ast.InlineFragment:
+			out = selectedFields(doc, sel.SelectionSet, prefix, seen, out)
+		case *ast.FragmentSpread:
+			if frag := doc.Fragments.ForName(sel.Name); frag != nil {
+				out = selectedFields(doc, frag.SelectionSet, prefix, seen, out)
+			}
+		}
+	}
+	return out
+}
+
+// writeGraphQL writes a GraphQL response document.
+func writeGraphQL(w http.ResponseWriter, status int, response map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// processGraphQLScenario answers from a scenario bound to the operation name,
+// falling back to one bound to the path alone. Bodies that are not already a
+// GraphQL response are sent as its data.
+func processGraphQLScenario(w http.ResponseWriter, r *http.Request, operation string) bool {
+	resp, ok := nextScenarioResponse(scenarioKey(r.URL.Path, operation))
+	if !ok && operation != "" {
+		resp, ok = nextScenarioResponse(r.URL.Path)
+	}
+	if !ok {
+		return false
+	}
+	if delay, ok := parseDelaySpec(resp.Delay, requestRand(r)); ok {
+		log.Printf("Scenario delay: %v", delay)
+		time.Sleep(delay)
+	}
+	body := []byte(resp.Body)
+	if resp.File != "" {
+		data, err := os.ReadFile(resp.File)
+		if err != nil {
+			log.Printf("Failed to read scenario file: %v", err)
+		}
+		body = data
+	}
+	var doc map[string]json.RawMessage
+	if json.Unmarshal(body, &doc) != nil || (doc["data"] == nil && doc["errors"] == nil) {
+		body, _ = json.Marshal(map[string]json.RawMessage{"data": body})
+	}
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.Header().Set("X-Echo-Scenario", "true")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+	return true
+}
+
+// graphqlHandler parses GraphQL requests, reports what they asked for, and answers
+// from a matching scenario or with data generated from the schema.
+// X-Echo-GraphQL-Errors / ECHO_GRAPHQL_ERRORS fails the listed fields, which yields
+// partial data and an errors array while the HTTP status stays 200.
+func graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	er, ok := beginEcho(w, r, readBody, nil)
+	if !ok {
+		return
+	}
+	r = er.r
+	graphqlSchemaMutex.RLock()
+	schema := graphqlSchema
+	graphqlSchemaMutex.RUnlock()
+
+	params, err := readGraphQLParams(r, er.body)
+	if err != nil {
+		writeGraphQL(w, http.StatusBadRequest, map[string]interface{}{"errors": gqlerror.List{gqlerror.Errorf("%v", err)}})
+		return
+	}
+
+	// Parse and validation errors are results, not transport failures
+	doc, errs := parseGraphQL(schema, params.Query)
+	if len(errs) > 0 {
+		writeGraphQL(w, http.StatusOK, map[string]interface{}{"errors": errs})
+		return
+	}
+	op, err := selectOperation(doc, params.OperationName)
+	if err != nil {
+		writeGraphQL(w, http.StatusOK, map[string]interface{}{"errors": gqlerror.List{gqlerror.Errorf("%v", err)}})
+		return
+	}
+	if r.Method == http.MethodGet && op.Operation != ast.Query {
+		w.Header().Set("Allow", "POST")
+		writeGraphQL(w, http.StatusMethodNotAllowed, map[string]interface{}{"errors": gqlerror.List{gqlerror.Errorf("%s operations must use POST", op.Operation)}})
+		return
+	}
+	vars := params.Variables
+	if schema != nil {
+		if vars, err = validator.VariableValues(schema, op, params.Variables); err != nil {
+			writeGraphQL(w, http.StatusOK, map[string]interface{}{"errors": gqlerror.List{gqlerror.WrapIfUnwrapped(err)}})
+			return
+		}
+	}
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+
+	w.Header().Set("X-Echo-GraphQL-Operation", op.Name)
+	w.Header().Set("X-Echo-GraphQL-Operation-Type", string(op.Operation))
+	if processGraphQLScenario(w, r, op.Name) {
+		return
+	}
+
+	resolver := &graphqlResolver{
+		schema: schema,
+		doc:    doc,
+		vars:   vars,
+		rnd:    requestRand(r),
+		faults: parseGraphQLFaults(getHeaderOrEnv(r, "X-Echo-GraphQL-Errors", "ECHO_GRAPHQL_ERRORS")),
+	}
+	response := map[string]interface{}{"data": resolver.execute(op)}
+	if len(resolver.errors) > 0 {
+		response["errors"] = resolver.errors
+	}
+	response["extensions"] = map[string]interface{}{"echo": map[string]interface{}{
+		"operationName": op.Name,
+		"operationType": op.Operation,
+		"variables":     vars,
+		"fields":        selectedFields(doc, op.SelectionSet, "", map[string]bool{}, nil),
+	}}
+	log.Printf("GraphQL: %s %s -> %d errors", op.Operation, op.Name, len(resolver.errors))
+	writeGraphQL(w, http.StatusOK, response)
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testGraphQLSchema = `
+type Query {
+  user(id: ID!): User
+  orders(first: Int): [Order!]
+  search(text: String!): [SearchResult!]!
+}
+type Mutation { createOrder(total: Float!): Order! }
+interface Node { id: ID! }
+type User implements Node { id: ID! name: String! email: String role: Role! }
+type Order implements Node { id: ID! total: Float! status: String! }
+union SearchResult = User | Order
+enum Role { ADMIN MEMBER }
+`
+
+func loadTestGraphQLSchema(t *testing.T) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "schema.graphql")
+	if err := os.WriteFile(path, []byte(testGraphQLSchema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loadGraphQLSchema(path)
+	if graphqlSchema == nil {
+		t.Fatal("schema did not load")
+	}
+}
+
+type graphqlResult struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Path       []interface{}          `json:"path"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+	Extensions struct {
+		Echo struct {
+			OperationName string                 `json:"operationName"`
+			OperationType string                 `json:"operationType"`
+			Variables     map[string]interface{} `json:"variables"`
+			Fields        []string               `json:"fields"`
+		} `json:"echo"`
+	} `json:"extensions"`
+}
+
+func postGraphQL(t *testing.T, query, operation string, variables map[string]interface{}, headers map[string]string) (*httptest.ResponseRecorder, graphqlResult) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "operationName": operation, "variables": variables})
+	req := httptest.NewRequest("POST", "/graphql", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	setupRoutes().ServeHTTP(rr, req)
+	var result graphqlResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("response is not JSON: %v: %s", err, rr.Body.String())
+	}
+	return rr, result
+}
+
+func TestGraphQLReportsOperation(t *testing.T) {
+	setupTest()
+	query := `query GetUser($id: ID!) { user(id: $id) { ...UserFields profile { bio } } }
+fragment UserFields on User { id name }`
+	rr, result := postGraphQL(t, query, "GetUser", map[string]interface{}{"id": "42"}, nil)
+	if rr.Code != http.StatusOK || len(result.Errors) != 0 {
+		t.Fatalf("unexpected response %d: %s", rr.Code, rr.Body.String())
+	}
+	echo := result.Extensions.Echo
+	if echo.OperationName != "GetUser" || echo.OperationType != "query" || echo.Variables["id"] != "42" {
+		t.Errorf("unexpected operation report %+v", echo)
+	}
+	if got := strings.Join(echo.Fields, ","); got != "user,user.id,user.name,user.profile,user.profile.bio" {
+		t.Errorf("unexpected fields %q", got)
+	}
+	user, _ := result.Data["user"].(map[string]interface{})
+	if profile, _ := user["profile"].(map[string]interface{}); profile["bio"] == nil || user["name"] == nil {
+		t.Errorf("schemaless data should follow the query shape, got %s", rr.Body.String())
+	}
+	if rr.Header().Get("X-Echo-GraphQL-Operation") != "GetUser" {
+		t.Errorf("missing operation header")
+	}
+}
+
+func TestGraphQLSchemaData(t *testing.T) {
+	setupTest()
+	loadTestGraphQLSchema(t)
+	query := `{
+  user(id: "1") { __typename id name role }
+  orders(first: 3) { id total }
+  search(text: "x") { __typename ... on Node { id } ... on User { name } }
+}`
+	rr, result := postGraphQL(t, query, "", nil, map[string]string{"X-Echo-Seed": "7"})
+	if len(result.Errors) != 0 {
+		t.Fatalf("unexpected errors: %s", rr.Body.String())
+	}
+	user := result.Data["user"].(map[string]interface{})
+	if user["__typename"] != "User" || (user["role"] != "ADMIN" && user["role"] != "MEMBER") {
+		t.Errorf("unexpected user %v", user)
+	}
+	if _, ok := user["id"].(string); !ok {
+		t.Errorf("ID should be a string, got %v", user["id"])
+	}
+	orders := result.Data["orders"].([]interface{})
+	if len(orders) != 3 {
+		t.Fatalf("first: 3 should return 3 orders, got %d", len(orders))
+	}
+	if _, ok := orders[0].(map[string]interface{})["total"].(float64); !ok {
+		t.Errorf("Float field should be a number, got %v", orders[0])
+	}
+	for _, item := range result.Data["search"].([]interface{}) {
+		hit := item.(map[string]interface{})
+		if hit["id"] == nil || (hit["__typename"] == "User") != (hit["name"] != nil) {
+			t.Errorf("fragments applied wrongly to %v", hit)
+		}
+	}
+	if !strings.HasPrefix(rr.Body.String(), `{"data":{"user":{"__typename":"User","id":`) {
+		t.Errorf("fields should keep selection order: %s", rr.Body.String())
+	}
+
+	_, again := postGraphQL(t, query, "", nil, map[string]string{"X-Echo-Seed": "7"})
+	first, _ := json.Marshal(result.Data)
+	second, _ := json.Marshal(again.Data)
+	if !bytes.Equal(first, second) {
+		t.Error("the same seed should generate the same data")
+	}
+
+	_, result = postGraphQL(t, `{ user(id: "1") { missing } }`, "", nil, nil)
+	if len(result.Errors) == 0 || result.Data != nil {
+		t.Errorf("validation errors should be reported without data, got %+v", result)
+	}
+}
+
+func TestGraphQLInjectedErrors(t *testing.T) {
+	setupTest()
+	loadTestGraphQLSchema(t)
+	query := `{ user(id: "1") { id email } orders { id status } }`
+
+	rr, result := postGraphQL(t, query, "", nil, map[string]string{"X-Echo-GraphQL-Errors": "user.email:FORBIDDEN"})
+	if rr.Code != http.StatusOK || len(result.Errors) != 1 {
+		t.Fatalf("expected one error with status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if result.Errors[0].Extensions["code"] != "FORBIDDEN" || len(result.Errors[0].Path) != 2 {
+		t.Errorf("unexpected error %+v", result.Errors[0])
+	}
+	user := result.Data["user"].(map[string]interface{})
+	if v, ok := user["email"]; !ok || v != nil || user["id"] == nil || result.Data["orders"] == nil {
+		t.Errorf("expected partial data with a null email, got %v", result.Data)
+	}
+
+	// A failed non-null field nulls its parent, up to the nullable list position
+	_, result = postGraphQL(t, query, "", nil, map[string]string{"X-Echo-GraphQL-Errors": "orders.status"})
+	if len(result.Errors) != graphqlListSize || result.Errors[0].Path[1] != float64(0) {
+		t.Fatalf("expected an error per order, got %+v", result.Errors)
+	}
+	if _, ok := result.Data["orders"]; !ok || result.Data["orders"] != nil || result.Data["user"] == nil {
+		t.Errorf("null should propagate to the orders field only, got %v", result.Data)
+	}
+
+	// Non-null root fields null the whole data
+	_, result = postGraphQL(t, `mutation { createOrder(total: 5) { id } }`, "", nil, map[string]string{"X-Echo-GraphQL-Errors": "*"})
+	if len(result.Errors) != 1 || result.Data != nil {
+		t.Errorf("expected null data, got %+v", result)
+	}
+}
+
+func TestGraphQLScenarioByOperation(t *testing.T) {
+	setupTest()
+	post := httptest.NewRequest("POST", "/scenario", strings.NewReader(`[
+		{"path": "/graphql", "operation": "GetUser", "responses": [
+			{"body": "{\"user\": {\"name\": \"Ada\"}}"},
+			{"body": "{\"data\": null, \"errors\": [{\"message\": \"down\"}]}"}
+		]}
+	]`))
+	scenarioHandler(httptest.NewRecorder(), post)
+
+	query := `query GetUser { user { name } } query Other { user { name } }`
+	_, result := postGraphQL(t, query, "GetUser", nil, nil)
+	if result.Data["user"].(map[string]interface{})["name"] != "Ada" {
+		t.Errorf("scenario data should be wrapped in data, got %+v", result)
+	}
+	rr, result := postGraphQL(t, query, "GetUser", nil, nil)
+	if rr.Code != http.StatusOK || len(result.Errors) != 1 || rr.Header().Get("X-Echo-Scenario") != "true" {
+		t.Errorf("second scenario response should be sent as-is, got %s", rr.Body.String())
+	}
+	_, result = postGraphQL(t, query, "Other", nil, nil)
+	if result.Extensions.Echo.OperationName != "Other" {
+		t.Errorf("other operations should be generated, got %+v", result)
+	}
+
+	rr = httptest.NewRecorder()
+	scenarioHandler(rr, httptest.NewRequest("GET", "/scenario", nil))
+	var listed []Scenario
+	json.Unmarshal(rr.Body.Bytes(), &listed)
+	if len(listed) != 1 || listed[0].Path != "/graphql" || listed[0].Operation != "GetUser" {
+		t.Errorf("unexpected scenario listing %+v", listed)
+	}
+}
+
+func TestGraphQLRequestErrors(t *testing.T) {
+	setupTest()
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve(httptest.NewRequest("POST", "/graphql", strings.NewReader("{not json")))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `"errors"`) {
+		t.Errorf("malformed JSON should be a 400 with errors, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = serve(httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ user { "}`)))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"errors"`) {
+		t.Errorf("syntax errors should be a 200 with errors, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = serve(httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape("mutation { reset }"), nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("mutations over GET should be rejected, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader("{ ping }"))
+	req.Header.Set("Content-Type", "application/graphql")
+	if rr = serve(req); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"ping":"ping-`) {
+		t.Errorf("application/graphql bodies should be executed, got %s", rr.Body.String())
+	}
+}
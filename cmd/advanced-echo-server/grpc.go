@@ -0,0 +1,430 @@
+package main
+
+//go:generate protoc --proto_path=echopb --go_out=echopb --go_opt=paths=source_relative --go-grpc_out=echopb --go-grpc_opt=paths=source_relative echo.proto
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arun0009/advanced-echo-server/cmd/advanced-echo-server/echopb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxGRPCStreamMessages bounds the responses a single ServerStream call may request.
+const maxGRPCStreamMessages = 100000
+
+// grpcCall is the HTTP view of a gRPC call, so the header-driven helpers (rules,
+// delays, seeds, history) work unchanged on x-echo-* metadata.
+type grpcCall struct {
+	r     *http.Request
+	count uint64
+}
+
+type grpcCallKey struct{}
+
+// echoServer implements the generic gRPC echo service.
+type echoServer struct {
+	echopb.UnimplementedEchoServiceServer
+}
+
+// headerRecorder collects the response headers set by the shared HTTP helpers so
+// they can be returned as gRPC header metadata.
+type headerRecorder struct{ header http.Header }
+
+func (h *headerRecorder) Header() http.Header         { return h.header }
+func (h *headerRecorder) Write(p []byte) (int, error) { return len(p), nil }
+func (h *headerRecorder) WriteHeader(int)             {}
+
+// grpcCodeNames maps normalized code names ("unavailable", "deadlineexceeded") to codes.
+var grpcCodeNames = func() map[string]codes.Code {
+	names := map[string]codes.Code{}
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		names[strings.ToLower(c.String())] = c
+	}
+	return names
+}()
+
+// parseGRPCCode accepts a code number (14), a name (UNAVAILABLE, deadline-exceeded)
+// or an HTTP status, which is mapped as gRPC gateways do.
+func parseGRPCCode(s string) (codes.Code, bool) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		switch {
+		case n >= 0 && n <= int(codes.Unauthenticated):
+			return codes.Code(n), true
+		case n >= 100 && n <= 599:
+			return httpStatusToGRPC(n), true
+		}
+		return 0, false
+	}
+	name := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(s))
+	code, ok := grpcCodeNames[name]
+	return code, ok
+}
+
+// httpStatusToGRPC follows the gRPC HTTP-to-status mapping, so rules and env
+// controls written with HTTP statuses also apply to gRPC calls.
+func httpStatusToGRPC(status int) codes.Code {
+	switch {
+	case status < 300:
+		return codes.OK
+	case status == http.StatusBadRequest:
+		return codes.Internal
+	case status == http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case status == http.StatusForbidden:
+		return codes.PermissionDenied
+	case status == http.StatusNotFound:
+		return codes.Unimplemented
+	case status == http.StatusRequestTimeout, status == http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case status == http.StatusTooManyRequests, status == http.StatusBadGateway, status == http.StatusServiceUnavailable:
+		return codes.Unavailable
+	}
+	return codes.Unknown
+}
+
+// pickGRPCCode picks a weighted code from a mix such as "UNAVAILABLE:3,INTERNAL:1",
+// falling back to the usual transient failures.
+func pickGRPCCode(mix string, rnd *lockedRand) codes.Code {
+	var choices []codes.Code
+	var weights []int
+	total := 0
+	for _, part := range splitList(mix) {
+		name, weight, found := strings.Cut(part, ":")
+		code, ok := parseGRPCCode(name)
+		if !ok || code == codes.OK {
+			continue
+		}
+		w := 1
+		if found {
+			var err error
+			if w, err = strconv.Atoi(strings.TrimSpace(weight)); err != nil || w <= 0 {
+				continue
+			}
+		}
+		choices = append(choices, code)
+		weights = append(weights, w)
+		total += w
+	}
+	if total == 0 {
+		defaults := []codes.Code{codes.Unavailable, codes.Internal, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted}
+		return defaults[rnd.Intn(len(defaults))]
+	}
+	n := rnd.Intn(total)
+	for i, w := range weights {
+		if n < w {
+			return choices[i]
+		}
+		n -= w
+	}
+	return choices[len(choices)-1]
+}
+
+// grpcRequest builds the HTTP view of a call: POST to the full method name with
+// the metadata as headers.
+func grpcRequest(ctx context.Context, fullMethod string) *http.Request {
+	md, _ := metadata.FromIncomingContext(ctx)
+	header := http.Header{}
+	host := ""
+	for key, values := range md {
+		if key == ":authority" && len(values) > 0 {
+			host = values[0]
+		}
+		if strings.HasPrefix(key, ":") {
+			continue
+		}
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+	if header.Get("X-Request-ID") == "" {
+		header.Set("X-Request-ID", generateRequestID())
+	}
+	r := &http.Request{
+		Method:     http.MethodPost,
+		URL:        &url.URL{Path: fullMethod},
+		RequestURI: fullMethod,
+		Proto:      "HTTP/2.0",
+		ProtoMajor: 2,
+		Header:     header,
+		Host:       host,
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		r.RemoteAddr = p.Addr.String()
+	}
+	return r.WithContext(ctx)
+}
+
+// beginGRPCCall applies the echo pipeline to a call: seed, route rule, counter,
+// history, delays and faults. It returns the call context, the header metadata to
+// send and any injected error.
+func beginGRPCCall(ctx context.Context, fullMethod string, body []byte) (context.Context, metadata.MD, error) {
+	rec := &headerRecorder{header: http.Header{}}
+	er, _ := beginEcho(rec, grpcRequest(ctx, fullMethod), func(http.ResponseWriter, *http.Request) ([]byte, bool) {
+		return body, true
+	}, nil)
+	r := er.r
+	rec.header.Set("X-Request-ID", r.Header.Get("X-Request-ID"))
+	err := grpcFault(r)
+
+	md := metadata.MD{}
+	for name, values := range rec.header {
+		md.Append(strings.ToLower(name), values...)
+	}
+	call := &grpcCall{r: r, count: er.count}
+	return context.WithValue(r.Context(), grpcCallKey{}, call), md, err
+}
+
+// grpcFault injects X-Echo-Status and X-Echo-Chaos failures as gRPC status errors.
+func grpcFault(r *http.Request) error {
+	if s := getHeaderOrEnv(r, "X-Echo-Status", "ECHO_STATUS"); s != "" {
+		if code, ok := parseGRPCCode(s); ok && code != codes.OK {
+			chaosErrors.WithLabelValues("grpc_status").Inc()
+			return status.Errorf(code, "Simulated %s", code)
+		}
+	}
+	if rate, err := strconv.Atoi(getHeaderOrEnv(r, "X-Echo-Chaos", "ECHO_CHAOS")); err == nil && rate > 0 && rate <= 100 {
+		rnd := requestRand(r)
+		if rnd.Intn(100) < rate {
+			code := pickGRPCCode(getHeaderOrEnv(r, "X-Echo-Chaos-Errors", "ECHO_CHAOS_ERRORS"), rnd)
+			log.Printf("Chaos: Injecting gRPC %s for %s (%d%% rate)", code, r.RemoteAddr, rate)
+			chaosErrors.WithLabelValues("grpc_chaos").Inc()
+			return status.Errorf(code, "Chaos error injection: %s", code)
+		}
+	}
+	return nil
+}
+
+// grpcAbort reads X-Echo-Abort / ECHO_ABORT ("3" or "3:UNAVAILABLE"): the number of
+// stream messages after which the call fails, and the error it fails with.
+func grpcAbort(r *http.Request) (int, error) {
+	count, codeName, _ := strings.Cut(getHeaderOrEnv(r, "X-Echo-Abort", "ECHO_ABORT"), ":")
+	n, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || n <= 0 {
+		return 0, nil
+	}
+	code := codes.Aborted
+	if c, ok := parseGRPCCode(codeName); ok && c != codes.OK {
+		code = c
+	}
+	return n, status.Errorf(code, "Stream aborted after %d messages", n)
+}
+
+// observeGRPCCall records a finished call in the request metrics, labelled by status code.
+func observeGRPCCall(fullMethod string, start time.Time, err error) {
+	requestLatency.Observe(time.Since(start).Seconds())
+	requestTotal.WithLabelValues(http.MethodPost, fullMethod, status.Code(err).String()).Inc()
+}
+
+// isEchoMethod limits the echo pipeline to the echo service, leaving health and
+// reflection unaffected by fault controls.
+func isEchoMethod(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/"+echopb.EchoService_ServiceDesc.ServiceName+"/")
+}
+
+func grpcUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if !isEchoMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	start := time.Now()
+	var body []byte
+	if msg, ok := req.(proto.Message); ok {
+		body, _ = protojson.Marshal(msg)
+	}
+	ctx, md, err := beginGRPCCall(ctx, info.FullMethod, body)
+	grpc.SetHeader(ctx, md)
+	var resp any
+	if err == nil {
+		resp, err = handler(ctx, req)
+	}
+	observeGRPCCall(info.FullMethod, start, err)
+	return resp, err
+}
+
+// grpcStream overrides the stream context with the one carrying the call.
+type grpcStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcStream) Context() context.Context { return s.ctx }
+
+func grpcStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !isEchoMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	start := time.Now()
+	ctx, md, err := beginGRPCCall(ss.Context(), info.FullMethod, nil)
+	ss.SetHeader(md)
+	if err == nil {
+		err = handler(srv, &grpcStream{ServerStream: ss, ctx: ctx})
+	}
+	observeGRPCCall(info.FullMethod, start, err)
+	return err
+}
+
+// echoResponse echoes req along with the call's metadata and peer.
+func echoResponse(ctx context.Context, req *echopb.EchoRequest, sequence int64) *echopb.EchoResponse {
+	resp := &echopb.EchoResponse{
+		Message:  req.GetMessage(),
+		Payload:  req.GetPayload(),
+		Sequence: sequence,
+		Metadata: map[string]string{},
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range md {
+			resp.Metadata[key] = strings.Join(values, ",")
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		resp.Peer = p.Addr.String()
+	}
+	if call, ok := ctx.Value(grpcCallKey{}).(*grpcCall); ok {
+		resp.RequestCount = call.count
+	}
+	return resp
+}
+
+// callRequest returns the HTTP view of the call in ctx.
+func callRequest(ctx context.Context) *http.Request {
+	if call, ok := ctx.Value(grpcCallKey{}).(*grpcCall); ok {
+		return call.r
+	}
+	return grpcRequest(ctx, "")
+}
+
+// Echo returns the request.
+func (echoServer) Echo(ctx context.Context, req *echopb.EchoRequest) (*echopb.EchoResponse, error) {
+	return echoResponse(ctx, req, 0), nil
+}
+
+// ServerStream sends the request count times, interval_ms plus X-Echo-Chunk-Delay apart.
+func (echoServer) ServerStream(req *echopb.EchoRequest, stream grpc.ServerStreamingServer[echopb.EchoResponse]) error {
+	ctx := stream.Context()
+	r := callRequest(ctx)
+	abortAfter, abortErr := grpcAbort(r)
+	count := min(max(int(req.GetCount()), 1), maxGRPCStreamMessages)
+	interval := min(time.Duration(req.GetIntervalMs())*time.Millisecond, maxStreamInterval)
+	interval += parseDurationValue(getHeaderOrEnv(r, "X-Echo-Chunk-Delay", "ECHO_CHUNK_DELAY"))
+	for i := 0; i < count; i++ {
+		if abortAfter > 0 && i == abortAfter {
+			chaosErrors.WithLabelValues("grpc_abort").Inc()
+			return abortErr
+		}
+		if i > 0 && !sleepContext(ctx, interval) {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		if err := stream.Send(echoResponse(ctx, req, int64(i))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClientStream concatenates every received message and replies once the client closes.
+func (echoServer) ClientStream(stream grpc.ClientStreamingServer[echopb.EchoRequest, echopb.EchoResponse]) error {
+	ctx := stream.Context()
+	abortAfter, abortErr := grpcAbort(callRequest(ctx))
+	combined := &echopb.EchoRequest{}
+	received := 0
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(echoResponse(ctx, combined, int64(received)))
+		}
+		if err != nil {
+			return err
+		}
+		received++
+		combined.Message += req.GetMessage()
+		combined.Payload = append(combined.Payload, req.GetPayload()...)
+		if abortAfter > 0 && received == abortAfter {
+			chaosErrors.WithLabelValues("grpc_abort").Inc()
+			return abortErr
+		}
+	}
+}
+
+// BidiStream echoes each message as it arrives, after X-Echo-Chunk-Delay.
+func (echoServer) BidiStream(stream grpc.BidiStreamingServer[echopb.EchoRequest, echopb.EchoResponse]) error {
+	ctx := stream.Context()
+	r := callRequest(ctx)
+	abortAfter, abortErr := grpcAbort(r)
+	delay := parseDurationValue(getHeaderOrEnv(r, "X-Echo-Chunk-Delay", "ECHO_CHUNK_DELAY"))
+	for sent := 0; ; sent++ {
+		if abortAfter > 0 && sent == abortAfter {
+			chaosErrors.WithLabelValues("grpc_abort").Inc()
+			return abortErr
+		}
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !sleepContext(ctx, delay) {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		if err := stream.Send(echoResponse(ctx, req, int64(sent))); err != nil {
+			return err
+		}
+	}
+}
+
+// newGRPCServer builds a gRPC server with the echo, health and reflection services.
+func newGRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.UnaryInterceptor(grpcUnaryInterceptor), grpc.StreamInterceptor(grpcStreamInterceptor))
+	server := grpc.NewServer(opts...)
+	echopb.RegisterEchoServiceServer(server, echoServer{})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(echopb.EchoService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	reflection.Register(server)
+	return server
+}
+
+// startGRPCServer serves gRPC on port, with TLS when the HTTP server uses it.
+func startGRPCServer(port string) error {
+	configLock.RLock()
+	enableTLS, certFile, keyFile := config.EnableTLS, config.CertFile, config.KeyFile
+	configLock.RUnlock()
+
+	var opts []grpc.ServerOption
+	if enableTLS {
+		ensureCertificate()
+		creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+	log.Printf("Starting gRPC server on port %s (TLS: %v)", port, enableTLS)
+	return newGRPCServer(opts...).Serve(listener)
+}
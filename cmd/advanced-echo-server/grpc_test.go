@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/arun0009/advanced-echo-server/cmd/advanced-echo-server/echopb"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+)
+
+// newGRPCTestClient serves newGRPCServer on a loopback port and dials it.
+func newGRPCTestClient(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := newGRPCServer()
+	go server.Serve(listener)
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+	})
+	return conn
+}
+
+// withEchoMetadata returns a call context carrying the given metadata pairs.
+func withEchoMetadata(t *testing.T, pairs ...string) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return metadata.AppendToOutgoingContext(ctx, pairs...)
+}
+
+func TestGRPCUnaryEcho(t *testing.T) {
+	setupTest()
+	client := echopb.NewEchoServiceClient(newGRPCTestClient(t))
+
+	var header metadata.MD
+	resp, err := client.Echo(withEchoMetadata(t, "x-trace", "abc", "x-request-id", "grpc-1"), &echopb.EchoRequest{Message: "hello", Payload: []byte{1, 2}}, grpc.Header(&header))
+	if err != nil {
+		t.Fatalf("Echo failed: %v", err)
+	}
+	if resp.GetMessage() != "hello" || len(resp.GetPayload()) != 2 || resp.GetMetadata()["x-trace"] != "abc" || resp.GetRequestCount() != 1 {
+		t.Errorf("unexpected response %v", resp)
+	}
+	if len(header.Get("x-echo-seed")) != 1 || header.Get("x-request-id")[0] != "grpc-1" {
+		t.Errorf("expected seed and request ID header metadata, got %v", header)
+	}
+
+	historyMutex.Lock()
+	recorded := len(requestHistory) == 1 && requestHistory[0].URL == "/echo.v1.EchoService/Echo" && requestHistory[0].ID == "grpc-1"
+	historyMutex.Unlock()
+	if !recorded {
+		t.Error("expected the call in request history")
+	}
+	if got := testutil.ToFloat64(requestTotal.WithLabelValues("POST", "/echo.v1.EchoService/Echo", "OK")); got != 1 {
+		t.Errorf("expected one OK call in metrics, got %v", got)
+	}
+}
+
+func TestGRPCFaults(t *testing.T) {
+	setupTest()
+	client := echopb.NewEchoServiceClient(newGRPCTestClient(t))
+	req := &echopb.EchoRequest{Message: "x"}
+
+	tests := []struct {
+		md   []string
+		want codes.Code
+	}{
+		{[]string{"x-echo-status", "UNAVAILABLE"}, codes.Unavailable},
+		{[]string{"x-echo-status", "5"}, codes.NotFound},
+		{[]string{"x-echo-status", "503"}, codes.Unavailable},
+		{[]string{"x-echo-chaos", "100", "x-echo-chaos-errors", "RESOURCE_EXHAUSTED"}, codes.ResourceExhausted},
+	}
+	for _, tt := range tests {
+		_, err := client.Echo(withEchoMetadata(t, tt.md...), req)
+		if status.Code(err) != tt.want {
+			t.Errorf("%v: expected %s, got %v", tt.md, tt.want, err)
+		}
+	}
+
+	start := time.Now()
+	if _, err := client.Echo(withEchoMetadata(t, "x-echo-delay", "100"), req); err != nil || time.Since(start) < 100*time.Millisecond {
+		t.Errorf("expected a 100ms delayed echo, got %v after %v", err, time.Since(start))
+	}
+}
+
+func TestGRPCStreams(t *testing.T) {
+	setupTest()
+	client := echopb.NewEchoServiceClient(newGRPCTestClient(t))
+
+	server, err := client.ServerStream(withEchoMetadata(t), &echopb.EchoRequest{Message: "tick", Count: 3, IntervalMs: 10})
+	if err != nil {
+		t.Fatalf("ServerStream failed: %v", err)
+	}
+	var sequences []int64
+	for {
+		resp, err := server.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ServerStream recv: %v", err)
+		}
+		sequences = append(sequences, resp.GetSequence())
+	}
+	if len(sequences) != 3 || sequences[2] != 2 {
+		t.Errorf("expected sequences 0..2, got %v", sequences)
+	}
+
+	upload, err := client.ClientStream(withEchoMetadata(t))
+	if err != nil {
+		t.Fatalf("ClientStream failed: %v", err)
+	}
+	for _, part := range []string{"a", "b", "c"} {
+		upload.Send(&echopb.EchoRequest{Message: part})
+	}
+	resp, err := upload.CloseAndRecv()
+	if err != nil || resp.GetMessage() != "abc" || resp.GetSequence() != 3 {
+		t.Errorf("ClientStream: got %v, %v", resp, err)
+	}
+
+	bidi, err := client.BidiStream(withEchoMetadata(t, "x-echo-abort", "2:UNAVAILABLE"))
+	if err != nil {
+		t.Fatalf("BidiStream failed: %v", err)
+	}
+	for i, msg := range []string{"one", "two"} {
+		bidi.Send(&echopb.EchoRequest{Message: msg})
+		resp, err := bidi.Recv()
+		if err != nil || resp.GetMessage() != msg || resp.GetSequence() != int64(i) {
+			t.Fatalf("BidiStream echo %d: got %v, %v", i, resp, err)
+		}
+	}
+	if _, err := bidi.Recv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected the stream to abort with Unavailable, got %v", err)
+	}
+}
+
+func TestGRPCHealthAndReflection(t *testing.T) {
+	setupTest()
+	conn := newGRPCTestClient(t)
+
+	// Fault controls in the environment must not affect health checks
+	t.Setenv("ECHO_STATUS", "503")
+	health, err := healthpb.NewHealthClient(conn).Check(withEchoMetadata(t), &healthpb.HealthCheckRequest{Service: "echo.v1.EchoService"})
+	if err != nil || health.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected SERVING, got %v, %v", health, err)
+	}
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(withEchoMetadata(t))
+	if err != nil {
+		t.Fatalf("reflection failed: %v", err)
+	}
+	stream.Send(&reflectionpb.ServerReflectionRequest{MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{}})
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("reflection recv: %v", err)
+	}
+	found := false
+	for _, svc := range resp.GetListServicesResponse().GetService() {
+		found = found || svc.GetName() == "echo.v1.EchoService"
+	}
+	if !found {
+		t.Errorf("echo service not listed by reflection: %v", resp)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Browser-facing RPC protocols answered on the HTTP listener. Messages are echoed
+// back byte for byte, so any service and method can be exercised.
+const (
+	rpcGRPCWeb       = "grpc-web"
+	rpcGRPCWebText   = "grpc-web-text"
+	rpcConnectUnary  = "connect"
+	rpcConnectStream = "connect-stream"
+)
+
+// Envelope flags shared by gRPC-Web and Connect streaming framing.
+const (
+	envelopeCompressed = 0x01
+	envelopeEndStream  = 0x02 // Connect end-of-stream message
+	envelopeTrailer    = 0x80 // gRPC-Web trailers frame
+)
+
+type envelope struct {
+	flags byte
+	data  []byte
+}
+
+// rpcWebProtocol identifies gRPC-Web and Connect requests by content type. Connect
+// unary requests use plain application/proto or application/json, so they also
+// need the Connect-Protocol-Version header to be told apart from ordinary echo traffic.
+func rpcWebProtocol(r *http.Request) string {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case r.Method != http.MethodPost:
+		return ""
+	case mediaType == "application/grpc-web-text" || strings.HasPrefix(mediaType, "application/grpc-web-text+"):
+		return rpcGRPCWebText
+	case mediaType == "application/grpc-web" || strings.HasPrefix(mediaType, "application/grpc-web+"):
+		return rpcGRPCWeb
+	case strings.HasPrefix(mediaType, "application/connect+"):
+		return rpcConnectStream
+	case (mediaType == "application/proto" || mediaType == "application/json") && r.Header.Get("Connect-Protocol-Version") == "1":
+		return rpcConnectUnary
+	}
+	return ""
+}
+
+// isRPCWebRequest is the router matcher for rpcWebHandler.
+func isRPCWebRequest(r *http.Request, _ *mux.RouteMatch) bool {
+	return rpcWebProtocol(r) != ""
+}
+
+// parseEnvelopes splits a body into length-prefixed messages.
+func parseEnvelopes(body []byte) ([]envelope, error) {
+	var envelopes []envelope
+	for len(body) > 0 {
+		if len(body) < 5 {
+			return nil, errors.New("truncated message prefix")
+		}
+		size := binary.BigEndian.Uint32(body[1:5])
+		if uint64(len(body)-5) < uint64(size) {
+			return nil, fmt.Errorf("message of %d bytes exceeds the remaining %d", size, len(body)-5)
+		}
+		envelopes = append(envelopes, envelope{flags: body[0], data: body[5 : 5+size]})
+		body = body[5+size:]
+	}
+	return envelopes, nil
+}
+
+func appendEnvelope(buf []byte, flags byte, data []byte) []byte {
+	buf = append(buf, flags)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(data)))
+	return append(buf, data...)
+}
+
+// encodeGRPCMessage percent-encodes a grpc-message value as the gRPC spec requires.
+func encodeGRPCMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if c := msg[i]; c >= 0x20 && c <= 0x7e && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// connectCodeName renders a code the way Connect spells it ("deadline_exceeded").
+func connectCodeName(code codes.Code) string {
+	var b strings.Builder
+	for i, c := range code.String() {
+		if unicode.IsUpper(c) && i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(c))
+	}
+	return b.String()
+}
+
+// connectHTTPStatus maps a code to the HTTP status of a Connect unary error.
+func connectHTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
+
+// connectErrorJSON is the Connect wire form of an error.
+func connectErrorJSON(st *status.Status) map[string]string {
+	return map[string]string{"code": connectCodeName(st.Code()), "message": st.Message()}
+}
+
+// decodeRPCMessages extracts the request messages from a gRPC-Web or Connect body.
+func decodeRPCMessages(protocol string, body []byte) ([][]byte, error) {
+	if protocol == rpcConnectUnary {
+		return [][]byte{body}, nil
+	}
+	if protocol == rpcGRPCWebText {
+		decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(body)))
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid base64 body: %v", err)
+		}
+		body = decoded
+	}
+	envelopes, err := parseEnvelopes(body)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid framing: %v", err)
+	}
+	var messages [][]byte
+	for _, env := range envelopes {
+		if env.flags&envelopeCompressed != 0 {
+			return nil, status.Error(codes.Unimplemented, "compressed messages are not supported")
+		}
+		if env.flags&(envelopeTrailer|envelopeEndStream) == 0 {
+			messages = append(messages, env.data)
+		}
+	}
+	return messages, nil
+}
+
+// rpcWebHandler echoes gRPC-Web and Connect messages with the protocol's framing,
+// trailers and error encoding. X-Echo-Status and X-Echo-Chaos inject gRPC codes,
+// X-Echo-Abort ends a stream early, and the delay controls apply as for echo.
+func rpcWebHandler(w http.ResponseWriter, r *http.Request) {
+	protocol := rpcWebProtocol(r)
+	er, ok := beginEcho(w, r, readBody, nil)
+	if !ok {
+		return
+	}
+	r, body := er.r, er.body
+
+	// Injected faults and framing errors are reported in-protocol, without messages
+	messages, rpcErr := decodeRPCMessages(protocol, body)
+	if err := grpcFault(r); err != nil {
+		messages, rpcErr = nil, err
+	}
+	if abortAfter, abortErr := grpcAbort(r); rpcErr == nil && abortAfter > 0 && abortAfter < len(messages) {
+		messages, rpcErr = messages[:abortAfter], abortErr
+		chaosErrors.WithLabelValues("grpc_abort").Inc()
+	}
+	st := status.Convert(rpcErr)
+	log.Printf("%s: %s -> %s (%d messages)", protocol, r.URL.Path, st.Code(), len(messages))
+
+	contentType := r.Header.Get("Content-Type")
+	switch protocol {
+	case rpcConnectUnary:
+		if rpcErr != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(connectHTTPStatus(st.Code()))
+			json.NewEncoder(w).Encode(connectErrorJSON(st))
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(messages[0])
+
+	case rpcConnectStream:
+		var out []byte
+		for _, msg := range messages {
+			out = appendEnvelope(out, 0, msg)
+		}
+		end := map[string]interface{}{}
+		if rpcErr != nil {
+			end["error"] = connectErrorJSON(st)
+		}
+		endJSON, _ := json.Marshal(end)
+		out = appendEnvelope(out, envelopeEndStream, endJSON)
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(out)
+
+	default:
+		var out []byte
+		for _, msg := range messages {
+			out = appendEnvelope(out, 0, msg)
+		}
+		trailer := fmt.Sprintf("grpc-status: %d\r\ngrpc-message: %s\r\n", st.Code(), encodeGRPCMessage(st.Message()))
+		out = appendEnvelope(out, envelopeTrailer, []byte(trailer))
+		if protocol == rpcGRPCWebText {
+			out = []byte(base64.StdEncoding.EncodeToString(out))
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(out)
+	}
+}
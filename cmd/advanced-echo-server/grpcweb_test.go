@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveRPCWeb(t *testing.T, contentType string, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/echo.v1.EchoService/Echo", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	setupRoutes().ServeHTTP(rr, req)
+	return rr
+}
+
+// grpcWebFrames splits a gRPC-Web response into messages and the trailer block.
+func grpcWebFrames(t *testing.T, body []byte) ([]string, string) {
+	t.Helper()
+	envelopes, err := parseEnvelopes(body)
+	if err != nil {
+		t.Fatalf("invalid response framing: %v", err)
+	}
+	var messages []string
+	trailer := ""
+	for _, env := range envelopes {
+		if env.flags&envelopeTrailer != 0 {
+			trailer = string(env.data)
+		} else {
+			messages = append(messages, string(env.data))
+		}
+	}
+	return messages, trailer
+}
+
+func TestGRPCWebEcho(t *testing.T) {
+	setupTest()
+	body := appendEnvelope(appendEnvelope(nil, 0, []byte("first")), 0, []byte("second"))
+
+	rr := serveRPCWeb(t, "application/grpc-web+proto", body, nil)
+	messages, trailer := grpcWebFrames(t, rr.Body.Bytes())
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/grpc-web+proto" {
+		t.Fatalf("unexpected response %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if len(messages) != 2 || messages[1] != "second" || !strings.Contains(trailer, "grpc-status: 0\r\n") {
+		t.Errorf("unexpected frames %q with trailer %q", messages, trailer)
+	}
+
+	rr = serveRPCWeb(t, "application/grpc-web-text", []byte(base64.StdEncoding.EncodeToString(body)), nil)
+	decoded, err := base64.StdEncoding.DecodeString(rr.Body.String())
+	if err != nil {
+		t.Fatalf("grpc-web-text response is not base64: %v", err)
+	}
+	if messages, _ := grpcWebFrames(t, decoded); len(messages) != 2 || messages[0] != "first" {
+		t.Errorf("unexpected grpc-web-text frames %q", messages)
+	}
+}
+
+func TestGRPCWebErrors(t *testing.T) {
+	setupTest()
+	body := appendEnvelope(appendEnvelope(nil, 0, []byte("first")), 0, []byte("second"))
+
+	rr := serveRPCWeb(t, "application/grpc-web+proto", body, map[string]string{"X-Echo-Status": "UNAVAILABLE"})
+	messages, trailer := grpcWebFrames(t, rr.Body.Bytes())
+	if rr.Code != http.StatusOK || len(messages) != 0 || !strings.Contains(trailer, "grpc-status: 14\r\ngrpc-message: Simulated Unavailable") {
+		t.Errorf("expected a trailers-only Unavailable response, got %d %q %q", rr.Code, messages, trailer)
+	}
+
+	rr = serveRPCWeb(t, "application/grpc-web+proto", body, map[string]string{"X-Echo-Abort": "1"})
+	messages, trailer = grpcWebFrames(t, rr.Body.Bytes())
+	if len(messages) != 1 || !strings.Contains(trailer, "grpc-status: 10\r\n") {
+		t.Errorf("expected one message then Aborted, got %q %q", messages, trailer)
+	}
+
+	rr = serveRPCWeb(t, "application/grpc-web+proto", []byte{0, 0, 0, 0, 9, 1}, nil)
+	if _, trailer = grpcWebFrames(t, rr.Body.Bytes()); !strings.Contains(trailer, "grpc-status: 3\r\n") {
+		t.Errorf("expected InvalidArgument for truncated framing, got %q", trailer)
+	}
+}
+
+func TestConnectUnary(t *testing.T) {
+	setupTest()
+	connect := map[string]string{"Connect-Protocol-Version": "1"}
+	rr := serveRPCWeb(t, "application/json", []byte(`{"message":"hi"}`), connect)
+	if rr.Code != http.StatusOK || rr.Body.String() != `{"message":"hi"}` {
+		t.Errorf("unexpected Connect echo %d %q", rr.Code, rr.Body.String())
+	}
+
+	connect["X-Echo-Status"] = "deadline_exceeded"
+	rr = serveRPCWeb(t, "application/proto", []byte("x"), connect)
+	var connectErr map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &connectErr)
+	if rr.Code != http.StatusGatewayTimeout || connectErr["code"] != "deadline_exceeded" {
+		t.Errorf("expected a Connect deadline_exceeded error, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	// Without Connect-Protocol-Version, JSON is ordinary echo traffic
+	rr = serveRPCWeb(t, "application/json", []byte(`{"message":"hi"}`), map[string]string{"X-Echo-Status": "unavailable"})
+	if rr.Code != http.StatusOK || rr.Body.String() != `{"message":"hi"}` {
+		t.Errorf("plain JSON should be echoed, got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestConnectStream(t *testing.T) {
+	setupTest()
+	body := appendEnvelope(appendEnvelope(nil, 0, []byte(`{"n":1}`)), 0, []byte(`{"n":2}`))
+
+	rr := serveRPCWeb(t, "application/connect+json", body, nil)
+	envelopes, err := parseEnvelopes(rr.Body.Bytes())
+	if err != nil || len(envelopes) != 3 || string(envelopes[1].data) != `{"n":2}` {
+		t.Fatalf("unexpected stream %q: %v", rr.Body.String(), err)
+	}
+	if end := envelopes[2]; end.flags != envelopeEndStream || strings.TrimSpace(string(end.data)) != "{}" {
+		t.Errorf("expected an empty end-stream message, got %q", end.data)
+	}
+
+	rr = serveRPCWeb(t, "application/connect+json", body, map[string]string{"X-Echo-Chaos": "100", "X-Echo-Chaos-Errors": "RESOURCE_EXHAUSTED"})
+	envelopes, _ = parseEnvelopes(rr.Body.Bytes())
+	var end struct {
+		Error map[string]string `json:"error"`
+	}
+	json.Unmarshal(envelopes[len(envelopes)-1].data, &end)
+	if len(envelopes) != 1 || end.Error["code"] != "resource_exhausted" {
+		t.Errorf("expected only an end-stream resource_exhausted error, got %q", rr.Body.String())
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadConfigList reads a YAML list from path, such as the route rules or outage
+// schedules. what names the file in log messages; ok is false when it could not
+// be read or parsed.
+func loadConfigList[T any](path, what string) (list []T, ok bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Failed to read %s file: %v", what, err)
+		return nil, false
+	}
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		log.Printf("Failed to parse %s file: %v", what, err)
+		return nil, false
+	}
+	return list, true
+}
+
+// configListHandler lists (GET) or replaces (POST) a list configured at runtime.
+// set installs the posted list and may reject it as invalid.
+func configListHandler[T any](w http.ResponseWriter, r *http.Request, what string, get func() []T, set func([]T) error) {
+	if r.Method == "GET" {
+		list := get()
+		if list == nil {
+			list = []T{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+		return
+	}
+
+	var list []T
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		http.Error(w, "Invalid "+what+" data", http.StatusBadRequest)
+		return
+	}
+	if err := set(list); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": what + " updated"})
+}
@@ -64,6 +64,8 @@ func replayHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	httpReq.Header = record.Headers.Clone()
+	// Let the transport negotiate compression so the replayed body arrives decoded
+	httpReq.Header.Del("Accept-Encoding")
 
 	resp, err := client.Do(httpReq)
 	if err != nil {
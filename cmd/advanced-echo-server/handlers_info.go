@@ -38,8 +38,7 @@ func infoHandler(w http.ResponseWriter, r *http.Request) {
 	} else {
 		body = []byte{}
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	info := map[string]interface{}{
 		"timestamp":    time.Now(),
 		"method":       r.Method,
 		"url":          r.RequestURI,
@@ -62,5 +61,11 @@ func infoHandler(w http.ResponseWriter, r *http.Request) {
 			"uptime":        time.Since(startTime).String(),
 			"request_count": requestCounter,
 		},
-	})
+		"schedule": currentPhase(time.Now()),
+	}
+	if quic := quicInfo(r); quic != nil {
+		info["quic"] = quic
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
 }
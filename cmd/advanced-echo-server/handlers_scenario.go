@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -14,9 +15,9 @@ func scenarioHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
 		var result []Scenario
 		scenarios.Range(func(key, value interface{}) bool {
-			path := key.(string)
+			path, operation, _ := strings.Cut(key.(string), "#")
 			responses := value.([]Response)
-			result = append(result, Scenario{Path: path, Responses: responses})
+			result = append(result, Scenario{Path: path, Operation: operation, Responses: responses})
 			return true
 		})
 		w.Header().Set("Content-Type", "application/json")
@@ -30,59 +31,114 @@ func scenarioHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	for _, s := range scenariosData {
-		scenarios.Store(s.Path, s.Responses)
-		scenarioIndex.Store(s.Path, 0)
+		key := scenarioKey(s.Path, s.Operation)
+		scenarios.Store(key, s.Responses)
+		scenarioIndex.Store(key, 0)
 	}
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"status": "scenarios updated"})
 }
 
+// scenarioKey is the key a scenario is stored under. Scenarios bound to a GraphQL
+// operation are kept apart from the plain path so each operation cycles on its own.
+func scenarioKey(path, operation string) string {
+	if operation == "" {
+		return path
+	}
+	return path + "#" + operation
+}
+
+// nextScenarioResponse returns the next response of the scenario stored under key,
+// cycling back to the first once all have been served.
+func nextScenarioResponse(key string) (Response, bool) {
+	scenario, ok := scenarios.Load(key)
+	if !ok {
+		return Response{}, false
+	}
+	responses := scenario.([]Response)
+	if len(responses) == 0 {
+		return Response{}, false
+	}
+	idx, _ := scenarioIndex.LoadOrStore(key, 0)
+	index := idx.(int) % len(responses)
+	scenarioIndex.Store(key, index+1)
+	return responses[index], true
+}
+
 // Process scenario responses
 func processScenario(w http.ResponseWriter, r *http.Request) bool {
-	scenario, ok := scenarios.Load(r.URL.Path)
+	resp, ok := nextScenarioResponse(r.URL.Path)
 	if !ok {
 		return false
 	}
-	responses := scenario.([]Response)
-	idx, _ := scenarioIndex.LoadOrStore(r.URL.Path, 0)
-	index := idx.(int) % len(responses)
-	resp := responses[index]
-	scenarioIndex.Store(r.URL.Path, index+1)
 
 	// Apply delay from scenario
-	if resp.Delay != "" {
-		if strings.Contains(resp.Delay, "-") {
-			parts := strings.Split(resp.Delay, "-")
-			if len(parts) == 2 {
-				minStr := strings.TrimSuffix(parts[0], "ms")
-				maxStr := strings.TrimSuffix(parts[1], "ms")
-				min, err1 := strconv.Atoi(minStr)
-				max, err2 := strconv.Atoi(maxStr)
-				if err1 == nil && err2 == nil && max >= min {
-					delay := min + rng.Intn(max-min+1)
-					log.Printf("Scenario delay: %dms (range: %d-%d)", delay, min, max)
-					time.Sleep(time.Duration(delay) * time.Millisecond)
-				}
-			}
-		} else {
-			delayStr := strings.TrimSuffix(resp.Delay, "ms")
-			if ms, err := strconv.Atoi(delayStr); err == nil {
-				if ms > 300000 {
-					ms = 300000
-				}
-				log.Printf("Scenario delay: %dms", ms)
-				time.Sleep(time.Duration(ms) * time.Millisecond)
-			}
-		}
+	if delay, ok := parseDelaySpec(resp.Delay, requestRand(r)); ok {
+		log.Printf("Scenario delay: %v", delay)
+		time.Sleep(delay)
 	}
 
 	w.Header().Set("X-Echo-Scenario", "true")
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.Status)
-	if resp.Body != "" {
-		w.Write([]byte(resp.Body))
-	} else {
-		w.Write([]byte(echoRequestInfo(r)))
+	shape := bodyShapeFor(r, resp)
+	stream, streaming := streamSpecFor(r, resp)
+
+	// Successful file-backed responses support ranges and validators
+	if resp.File != "" && resp.Status == http.StatusOK && !streaming {
+		serveFileEntity(w, r, resp.File, shape)
+		return true
 	}
+	body := []byte(resp.Body)
+	if resp.File != "" {
+		data, err := os.ReadFile(resp.File)
+		if err != nil {
+			log.Printf("Failed to read scenario file: %v", err)
+		}
+		body = data
+	} else if resp.Body == "" {
+		body = []byte(echoRequestInfo(r))
+	}
+	if streaming {
+		writeStream(w, r, resp.Status, body, stream)
+		return true
+	}
+	body = compressResponse(w, r, body)
+	if resp.Status == http.StatusOK {
+		serveBytesEntity(w, r, body, shape)
+		return true
+	}
+	writeShapedBody(w, r, resp.Status, body, shape)
 	return true
 }
+
+// parseDelaySpec parses a fixed ("500ms") or ranged ("100-500ms") delay as used by
+// scenarios and proxy faults. Ranged delays are sampled uniformly; anything else is
+// tried as a latency distribution spec.
+func parseDelaySpec(spec string, rnd *lockedRand) (time.Duration, bool) {
+	if spec == "" {
+		return 0, false
+	}
+	if dist, err := parseLatencyDist(spec); err == nil {
+		return dist.sample(rnd), true
+	}
+	if strings.Contains(spec, "-") {
+		parts := strings.Split(spec, "-")
+		if len(parts) != 2 {
+			return 0, false
+		}
+		min, err1 := strconv.Atoi(strings.TrimSuffix(parts[0], "ms"))
+		max, err2 := strconv.Atoi(strings.TrimSuffix(parts[1], "ms"))
+		if err1 != nil || err2 != nil || max < min {
+			return 0, false
+		}
+		return time.Duration(min+rnd.Intn(max-min+1)) * time.Millisecond, true
+	}
+	ms, err := strconv.Atoi(strings.TrimSuffix(spec, "ms"))
+	if err != nil {
+		return 0, false
+	}
+	if ms > 300000 {
+		ms = 300000
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net/http"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+type quicConnKey struct{}
+
+// newHTTP3Server builds the QUIC listener for handler. HTTP/3 always runs over
+// TLS, so the configured certificate is used, or generated, whether or not
+// ENABLE_TLS is set for the TCP listener.
+func newHTTP3Server(port string, handler http.Handler) (*http3.Server, error) {
+	ensureCertificate()
+	configLock.RLock()
+	certFile, keyFile := config.CertFile, config.KeyFile
+	configLock.RUnlock()
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &http3.Server{
+		Addr:      ":" + port,
+		Handler:   handler,
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+		// 0-RTT is on by default; keep the connection so /info can report it
+		ConnContext: func(ctx context.Context, conn *quic.Conn) context.Context {
+			return context.WithValue(ctx, quicConnKey{}, conn)
+		},
+	}, nil
+}
+
+// startHTTP3Server serves handler over HTTP/3 on the given UDP port.
+func startHTTP3Server(port string, handler http.Handler) error {
+	server, err := newHTTP3Server(port, handler)
+	if err != nil {
+		return err
+	}
+	log.Printf("Starting HTTP/3 server on UDP port %s", port)
+	return server.ListenAndServe()
+}
+
+// altSvcMiddleware advertises the HTTP/3 listener to clients of the TCP listeners.
+func altSvcMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		configLock.RLock()
+		port := config.HTTP3Port
+		configLock.RUnlock()
+		w.Header().Set("Alt-Svc", `h3=":`+port+`"; ma=86400`)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// quicInfo describes the QUIC connection a request arrived on, or returns nil
+// for requests over TCP.
+func quicInfo(r *http.Request) map[string]interface{} {
+	conn, ok := r.Context().Value(quicConnKey{}).(*quic.Conn)
+	if !ok {
+		return nil
+	}
+	state := conn.ConnectionState()
+	return map[string]interface{}{
+		"version":       state.Version.String(),
+		"used_0rtt":     state.Used0RTT,
+		"tls_resumed":   state.TLS.DidResume,
+		"cipher_suite":  tls.CipherSuiteName(state.TLS.CipherSuite),
+		"datagrams":     state.SupportsDatagrams.Remote && state.SupportsDatagrams.Local,
+		"local_address": conn.LocalAddr().String(),
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestHTTP3ServesRouterWithQUICInfo(t *testing.T) {
+	setupTest()
+	dir := t.TempDir()
+	configLock.Lock()
+	config.CertFile = filepath.Join(dir, "server.crt")
+	config.KeyFile = filepath.Join(dir, "server.key")
+	configLock.Unlock()
+	generateSelfSignedCert()
+
+	server, err := newHTTP3Server("0", setupRoutes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(conn)
+	defer server.Close()
+
+	transport := &http3.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	defer transport.Close()
+	client := &http.Client{Transport: transport}
+	resp, err := client.Get("https://" + conn.LocalAddr().String() + "/info")
+	if err != nil {
+		t.Fatalf("HTTP/3 request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var info struct {
+		Protocol string                 `json:"protocol"`
+		QUIC     map[string]interface{} `json:"quic"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ProtoMajor != 3 || info.Protocol != "HTTP/3.0" {
+		t.Errorf("expected HTTP/3, got %s (server saw %s)", resp.Proto, info.Protocol)
+	}
+	if info.QUIC["version"] != "v1" || info.QUIC["used_0rtt"] != false {
+		t.Errorf("unexpected QUIC details %v", info.QUIC)
+	}
+}
+
+func TestAltSvcAdvertisement(t *testing.T) {
+	setupTest()
+	rr := httptest.NewRecorder()
+	setupRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/info", nil))
+	if rr.Header().Get("Alt-Svc") != "" {
+		t.Errorf("Alt-Svc should only be sent when HTTP/3 is enabled")
+	}
+	var info map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &info)
+	if _, ok := info["quic"]; ok {
+		t.Errorf("TCP requests should not report QUIC details")
+	}
+
+	configLock.Lock()
+	config.HTTP3Port = "8443"
+	configLock.Unlock()
+	rr = httptest.NewRecorder()
+	setupRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if got := rr.Header().Get("Alt-Svc"); got != `h3=":8443"; ma=86400` {
+		t.Errorf("unexpected Alt-Svc %q", got)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// processExpect governs "Expect: 100-continue" per X-Echo-Expect / ECHO_EXPECT:
+//
+//	accept       send 100 Continue as soon as the body is read (the default)
+//	reject[:N]   answer 417 (or status N) without reading the body
+//	delay:<d>    wait before reading the body, holding back 100 Continue
+//
+// It returns true when the request was rejected. It must run before the body is read.
+func processExpect(w http.ResponseWriter, r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Expect"), "100-continue") {
+		return false
+	}
+	mode, arg, _ := strings.Cut(getHeaderOrEnv(r, "X-Echo-Expect", "ECHO_EXPECT"), ":")
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "reject":
+		status := http.StatusExpectationFailed
+		if n, err := strconv.Atoi(strings.TrimSpace(arg)); err == nil && n >= 400 && n <= 599 {
+			status = n
+		}
+		log.Printf("Expect: rejecting 100-continue from %s with %d", r.RemoteAddr, status)
+		w.Header().Set("Connection", "close")
+		http.Error(w, "Expectation rejected", status)
+		chaosErrors.WithLabelValues("expect_reject").Inc()
+		return true
+	case "delay":
+		if d := parseDurationValue(arg); d > 0 {
+			log.Printf("Expect: delaying 100 Continue by %v", d)
+			sleepContext(r.Context(), min(d, 300*time.Second))
+		}
+	}
+	return false
+}
+
+// processInformational sends 1xx responses ahead of the final status, as listed by
+// X-Echo-Informational / ECHO_INFORMATIONAL ("102:500ms,103"), each optionally
+// followed by a pause. X-Echo-Early-Hints / ECHO_EARLY_HINTS supplies the Link
+// header for 103 Early Hints and implies a single 103 when no list is given.
+func processInformational(w http.ResponseWriter, r *http.Request) {
+	spec := getHeaderOrEnv(r, "X-Echo-Informational", "ECHO_INFORMATIONAL")
+	hints := getHeaderOrEnv(r, "X-Echo-Early-Hints", "ECHO_EARLY_HINTS")
+	if spec == "" && hints != "" {
+		spec = "103"
+	}
+	if spec == "" {
+		return
+	}
+	for _, part := range strings.Split(spec, ",") {
+		codeStr, pause, _ := strings.Cut(strings.TrimSpace(part), ":")
+		code, err := strconv.Atoi(codeStr)
+		if err != nil || code < 100 || code > 199 || code == http.StatusSwitchingProtocols {
+			log.Printf("Invalid informational status %q", part)
+			continue
+		}
+		if code == http.StatusEarlyHints && hints != "" {
+			w.Header().Set("Link", hints)
+		}
+		log.Printf("Informational: sending %d %s", code, http.StatusText(code))
+		w.WriteHeader(code)
+		if !sleepContext(r.Context(), min(parseDurationValue(pause), 300*time.Second)) {
+			return
+		}
+	}
+	w.Header().Set("X-Echo-Informational", spec)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInformationalResponses(t *testing.T) {
+	setupTest()
+	server := httptest.NewServer(loggingMiddleware(http.HandlerFunc(echoHandler)))
+	defer server.Close()
+
+	var codes []int
+	var link string
+	trace := &httptrace.ClientTrace{Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+		codes = append(codes, code)
+		if code == http.StatusEarlyHints {
+			link = header.Get("Link")
+		}
+		return nil
+	}}
+	req, _ := http.NewRequest("GET", server.URL+"/page", nil)
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	req.Header.Set("X-Echo-Informational", "102,103")
+	req.Header.Set("X-Echo-Early-Hints", "</app.css>; rel=preload; as=style")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || len(codes) != 2 || codes[0] != 102 || codes[1] != 103 {
+		t.Errorf("expected 102 and 103 before 200, got %v then %d", codes, resp.StatusCode)
+	}
+	if link != "</app.css>; rel=preload; as=style" {
+		t.Errorf("expected the Link header on 103, got %q", link)
+	}
+}
+
+func TestExpectContinue(t *testing.T) {
+	setupTest()
+	server := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer server.Close()
+	client := &http.Client{Transport: &http.Transport{ExpectContinueTimeout: 5 * time.Second}}
+	defer client.CloseIdleConnections()
+
+	send := func(mode string) (*http.Response, string, time.Duration) {
+		var continued time.Duration
+		start := time.Now()
+		trace := &httptrace.ClientTrace{Got100Continue: func() { continued = time.Since(start) }}
+		req, _ := http.NewRequest("POST", server.URL+"/upload", strings.NewReader("payload"))
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+		req.Header.Set("Expect", "100-continue")
+		req.Header.Set("X-Echo-Expect", mode)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", mode, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(body), continued
+	}
+
+	resp, body, continued := send("accept")
+	if resp.StatusCode != http.StatusOK || body != "payload" || continued == 0 {
+		t.Errorf("accept: got %d %q (100 Continue after %v)", resp.StatusCode, body, continued)
+	}
+
+	resp, _, continued = send("reject")
+	if resp.StatusCode != http.StatusExpectationFailed || continued != 0 {
+		t.Errorf("reject: expected 417 without 100 Continue, got %d", resp.StatusCode)
+	}
+
+	resp, body, continued = send("delay:300ms")
+	if resp.StatusCode != http.StatusOK || body != "payload" || continued < 300*time.Millisecond {
+		t.Errorf("delay: got %d %q with 100 Continue after %v", resp.StatusCode, body, continued)
+	}
+
+	// A bare number is milliseconds, as for the other delay controls
+	resp, _, continued = send("delay:200")
+	if resp.StatusCode != http.StatusOK || continued < 200*time.Millisecond {
+		t.Errorf("delay in ms: got %d with 100 Continue after %v", resp.StatusCode, continued)
+	}
+}
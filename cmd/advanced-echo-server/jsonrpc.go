@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Standard JSON-RPC 2.0 error codes.
+const (
+	jsonrpcParseError     = -32700
+	jsonrpcInvalidRequest = -32600
+	jsonrpcMethodNotFound = -32601
+	jsonrpcInvalidParams  = -32602
+	jsonrpcInternalError  = -32603
+)
+
+var jsonrpcErrorMessages = map[int]string{
+	jsonrpcParseError:     "Parse error",
+	jsonrpcInvalidRequest: "Invalid Request",
+	jsonrpcMethodNotFound: "Method not found",
+	jsonrpcInvalidParams:  "Invalid params",
+	jsonrpcInternalError:  "Internal error",
+}
+
+type jsonrpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+type jsonrpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonrpcError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// newJSONRPCError fills in the standard message for codes that have one.
+func newJSONRPCError(code int) *jsonrpcError {
+	message, ok := jsonrpcErrorMessages[code]
+	if !ok && code <= -32000 && code >= -32099 {
+		message = "Server error"
+	}
+	return &jsonrpcError{Code: code, Message: message}
+}
+
+func jsonrpcErrorResponse(id json.RawMessage, code int) jsonrpcResponse {
+	return jsonrpcResponse{JSONRPC: "2.0", Error: newJSONRPCError(code), ID: id}
+}
+
+// validJSONRPCID reports whether id is a string, number or null.
+func validJSONRPCID(id json.RawMessage) bool {
+	var v interface{}
+	if json.Unmarshal(id, &v) != nil {
+		return false
+	}
+	switch v.(type) {
+	case string, float64, nil:
+		return true
+	}
+	return false
+}
+
+// processJSONRPC answers a single call or a batch. It returns nil when nothing is
+// to be sent back, which is the case for notifications and all-notification batches.
+func processJSONRPC(r *http.Request, payload []byte) []byte {
+	payload = bytes.TrimSpace(payload)
+	if !json.Valid(payload) {
+		out, _ := json.Marshal(jsonrpcErrorResponse(nil, jsonrpcParseError))
+		return out
+	}
+	if payload[0] != '[' {
+		resp, ok := callJSONRPC(r, payload)
+		if !ok {
+			return nil
+		}
+		out, _ := json.Marshal(resp)
+		return out
+	}
+
+	var batch []json.RawMessage
+	json.Unmarshal(payload, &batch)
+	if len(batch) == 0 {
+		out, _ := json.Marshal(jsonrpcErrorResponse(nil, jsonrpcInvalidRequest))
+		return out
+	}
+	var responses []jsonrpcResponse
+	for _, call := range batch {
+		if resp, ok := callJSONRPC(r, call); ok {
+			responses = append(responses, resp)
+		}
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+	out, _ := json.Marshal(responses)
+	return out
+}
+
+// callJSONRPC runs one call. Params are echoed as the result unless a scenario is
+// bound to the method. It returns false for notifications, which get no response.
+func callJSONRPC(r *http.Request, raw json.RawMessage) (jsonrpcResponse, bool) {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(raw, &fields) != nil {
+		return jsonrpcErrorResponse(nil, jsonrpcInvalidRequest), true
+	}
+	id, hasID := fields["id"]
+	if hasID && !validJSONRPCID(id) {
+		return jsonrpcErrorResponse(nil, jsonrpcInvalidRequest), true
+	}
+	var version, method string
+	if json.Unmarshal(fields["jsonrpc"], &version) != nil || version != "2.0" ||
+		json.Unmarshal(fields["method"], &method) != nil || method == "" {
+		return jsonrpcErrorResponse(id, jsonrpcInvalidRequest), true
+	}
+
+	resp := jsonrpcResponse{JSONRPC: "2.0", ID: id}
+	params, hasParams := fields["params"]
+	if hasParams && params[0] != '[' && params[0] != '{' {
+		resp.Error = newJSONRPCError(jsonrpcInvalidParams)
+	} else if result, rpcErr, ok := jsonrpcScenario(r, method); ok {
+		resp.Result, resp.Error = result, rpcErr
+	} else {
+		resp.Result = params
+	}
+	if resp.Error == nil && resp.Result == nil {
+		resp.Result = json.RawMessage("null")
+	}
+	if resp.Error != nil {
+		resp.Result = nil
+	}
+	log.Printf("JSON-RPC: %s (notification: %t, error: %t)", method, !hasID, resp.Error != nil)
+	return resp, hasID
+}
+
+// jsonrpcScenario answers from a scenario bound to the method, falling back to one
+// bound to the path alone. A body with a "result" or "error" member supplies that
+// member; any other JSON body is the result, and an empty body echoes the params.
+func jsonrpcScenario(r *http.Request, method string) (json.RawMessage, *jsonrpcError, bool) {
+	resp, ok := nextScenarioResponse(scenarioKey(r.URL.Path, method))
+	if !ok {
+		resp, ok = nextScenarioResponse(r.URL.Path)
+	}
+	if !ok {
+		return nil, nil, false
+	}
+	if delay, ok := parseDelaySpec(resp.Delay, requestRand(r)); ok {
+		log.Printf("Scenario delay: %v", delay)
+		time.Sleep(delay)
+	}
+	body := []byte(resp.Body)
+	if resp.File != "" {
+		data, err := os.ReadFile(resp.File)
+		if err != nil {
+			log.Printf("Failed to read scenario file: %v", err)
+		}
+		body = data
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, nil, false
+	}
+	if !json.Valid(body) {
+		result, _ := json.Marshal(string(body))
+		return result, nil, true
+	}
+	var envelope struct {
+		Result json.RawMessage `json:"result"`
+		Error  *jsonrpcError   `json:"error"`
+	}
+	var members map[string]json.RawMessage
+	if json.Unmarshal(body, &members) == nil && (members["result"] != nil || members["error"] != nil) {
+		json.Unmarshal(body, &envelope)
+		if envelope.Error != nil && envelope.Error.Message == "" {
+			envelope.Error.Message = newJSONRPCError(envelope.Error.Code).Message
+		}
+		return envelope.Result, envelope.Error, true
+	}
+	return body, nil, true
+}
+
+// jsonrpcHandler serves JSON-RPC 2.0 over HTTP POST, and over WebSocket when the
+// request is an upgrade, with one call or batch per message.
+func jsonrpcHandler(w http.ResponseWriter, r *http.Request) {
+	// WebSocket calls arrive as messages, each set up as a request of its own
+	if websocket.IsWebSocketUpgrade(r) {
+		jsonrpcWebSocket(w, r)
+		return
+	}
+	er, ok := beginEcho(w, r, func(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "JSON-RPC calls must use POST or a WebSocket", http.StatusMethodNotAllowed)
+			return nil, false
+		}
+		return readBody(w, r)
+	}, nil)
+	if !ok {
+		return
+	}
+
+	out := processJSONRPC(er.r, er.body)
+	if out == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(out)
+}
+
+// jsonrpcWebSocket answers each text message as a JSON-RPC call or batch. Every
+// message runs the echo setup (seed, route rule, counter, history and delays) as
+// if it were a separate request.
+func jsonrpcWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade error: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	log.Printf("JSON-RPC WebSocket connected: %s", r.RemoteAddr)
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		er, _ := beginEcho(&headerRecorder{header: http.Header{}}, r, func(http.ResponseWriter, *http.Request) ([]byte, bool) {
+			return message, true
+		}, nil)
+		if out := processJSONRPC(er.r, message); out != nil {
+			if err := conn.WriteMessage(websocket.TextMessage, out); err != nil {
+				log.Printf("WebSocket write error: %v", err)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func postJSONRPC(t *testing.T, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/jsonrpc", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	setupRoutes().ServeHTTP(rr, req)
+	return rr
+}
+
+func TestJSONRPCEchoesParams(t *testing.T) {
+	setupTest()
+	rr := postJSONRPC(t, `{"jsonrpc": "2.0", "method": "eth_getBalance", "params": ["0xabc", "latest"], "id": 7}`)
+	if rr.Code != http.StatusOK || rr.Body.String() != `{"jsonrpc":"2.0","result":["0xabc","latest"],"id":7}` {
+		t.Errorf("unexpected response %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = postJSONRPC(t, `{"jsonrpc": "2.0", "method": "initialized", "params": {}}`)
+	if rr.Code != http.StatusNoContent || rr.Body.Len() != 0 {
+		t.Errorf("notifications should get no response, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	rr = postJSONRPC(t, `{"jsonrpc": "2.0", "method": "ping", "id": "a"}`)
+	if rr.Body.String() != `{"jsonrpc":"2.0","result":null,"id":"a"}` {
+		t.Errorf("calls without params should return a null result, got %s", rr.Body.String())
+	}
+}
+
+func TestJSONRPCBatchAndErrors(t *testing.T) {
+	setupTest()
+	rr := postJSONRPC(t, `[
+		{"jsonrpc": "2.0", "method": "sum", "params": [1, 2], "id": 1},
+		{"jsonrpc": "2.0", "method": "notify", "params": [3]},
+		{"jsonrpc": "1.0", "method": "old", "id": 2},
+		{"jsonrpc": "2.0", "method": "bad", "params": 5, "id": 3},
+		1
+	]`)
+	var responses []jsonrpcResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &responses); err != nil || len(responses) != 4 {
+		t.Fatalf("expected 4 responses, got %s", rr.Body.String())
+	}
+	wantCodes := []int{0, jsonrpcInvalidRequest, jsonrpcInvalidParams, jsonrpcInvalidRequest}
+	for i, resp := range responses {
+		code := 0
+		if resp.Error != nil {
+			code = resp.Error.Code
+		}
+		if code != wantCodes[i] {
+			t.Errorf("response %d: expected code %d, got %s", i, wantCodes[i], rr.Body.String())
+		}
+	}
+	if string(responses[2].ID) != "3" || string(responses[3].ID) != "null" {
+		t.Errorf("errors should keep the call id when it is known: %s", rr.Body.String())
+	}
+
+	cases := map[string]int{`{"jsonrpc": "2.0", "method"`: jsonrpcParseError, `[]`: jsonrpcInvalidRequest}
+	for body, code := range cases {
+		var resp jsonrpcResponse
+		json.Unmarshal(postJSONRPC(t, body).Body.Bytes(), &resp)
+		if resp.Error == nil || resp.Error.Code != code || resp.Error.Message != jsonrpcErrorMessages[code] {
+			t.Errorf("%s: expected error %d, got %+v", body, code, resp.Error)
+		}
+	}
+
+	rr = postJSONRPC(t, `[{"jsonrpc": "2.0", "method": "a"}, {"jsonrpc": "2.0", "method": "b"}]`)
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("a batch of notifications should get no response, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestJSONRPCScenarioByMethod(t *testing.T) {
+	setupTest()
+	post := httptest.NewRequest("POST", "/scenario", strings.NewReader(`[
+		{"path": "/jsonrpc", "operation": "eth_blockNumber", "responses": [
+			{"body": "\"0x10\"", "delay": "50ms"},
+			{"body": "{\"error\": {\"code\": -32601}}"},
+			{"body": "{\"error\": {\"code\": -32000, \"message\": \"header not found\", \"data\": {\"block\": 5}}}"}
+		]}
+	]`))
+	scenarioHandler(httptest.NewRecorder(), post)
+
+	call := `{"jsonrpc": "2.0", "method": "eth_blockNumber", "id": 1}`
+	start := time.Now()
+	rr := postJSONRPC(t, call)
+	if rr.Body.String() != `{"jsonrpc":"2.0","result":"0x10","id":1}` || time.Since(start) < 50*time.Millisecond {
+		t.Errorf("expected a delayed scenario result, got %s", rr.Body.String())
+	}
+	if rr = postJSONRPC(t, call); rr.Body.String() != `{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":1}` {
+		t.Errorf("expected a standard error object, got %s", rr.Body.String())
+	}
+	if rr = postJSONRPC(t, call); !strings.Contains(rr.Body.String(), `"message":"header not found","data":{"block":5}`) {
+		t.Errorf("expected a custom error object, got %s", rr.Body.String())
+	}
+
+	rr = postJSONRPC(t, `{"jsonrpc": "2.0", "method": "eth_chainId", "params": [], "id": 2}`)
+	if rr.Body.String() != `{"jsonrpc":"2.0","result":[],"id":2}` {
+		t.Errorf("other methods should echo, got %s", rr.Body.String())
+	}
+}
+
+func TestJSONRPCWebSocket(t *testing.T) {
+	setupTest()
+	server := newTrackedServer(t, setupRoutes())
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/jsonrpc", nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	// The notification gets no reply, so the next message read answers the call
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc": "2.0", "method": "$/progress", "params": {}}`))
+	conn.WriteMessage(websocket.TextMessage, []byte(`[{"jsonrpc": "2.0", "method": "textDocument/hover", "params": {"line": 3}, "id": 1}]`))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := conn.ReadMessage()
+	if err != nil || string(message) != `[{"jsonrpc":"2.0","result":{"line":3},"id":1}]` {
+		t.Errorf("unexpected reply %s: %v", message, err)
+	}
+	// Each message is counted as a request of its own
+	counterMutex.Lock()
+	count := requestCounter
+	counterMutex.Unlock()
+	if count != 2 {
+		t.Errorf("expected 2 requests counted for 2 messages, got %d", count)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxInjectedDelay caps every sampled delay, matching the 300000ms limit used by the other delay controls.
+const maxInjectedDelay = 300 * time.Second
+
+// latencyDist is a parametric delay distribution.
+type latencyDist struct {
+	kind   string  // fixed, normal, lognormal or pareto
+	a, b   float64 // distribution parameters in milliseconds; lognormal stores mu/sigma of ln(ms)
+	maxCap time.Duration
+}
+
+// parseLatencyDist parses a latency distribution spec:
+//
+//	2s | fixed:2s
+//	normal:mean=100ms,stddev=20ms
+//	lognormal:median=50ms,sigma=0.8
+//	pareto:min=10ms,alpha=1.5
+//	p50=20ms,p99=800ms,p999=3s   (log-normal fitted to the percentiles)
+//
+// Every form accepts an optional max=<duration> cap.
+func parseLatencyDist(spec string) (latencyDist, error) {
+	spec = strings.TrimSpace(spec)
+	if d := parseDurationValue(spec); d > 0 {
+		return latencyDist{kind: "fixed", a: durationMs(d), maxCap: maxInjectedDelay}, nil
+	}
+	kind, params, found := strings.Cut(spec, ":")
+	if !found {
+		kind, params = "percentiles", spec
+	}
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	values := map[string]string{}
+	for _, part := range strings.Split(params, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			if kind == "fixed" {
+				key, value = "value", part
+			} else {
+				return latencyDist{}, fmt.Errorf("invalid latency parameter %q", part)
+			}
+		}
+		values[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+
+	dist := latencyDist{kind: kind, maxCap: maxInjectedDelay}
+	if v, ok := values["max"]; ok {
+		if d := parseDurationValue(v); d > 0 && d < maxInjectedDelay {
+			dist.maxCap = d
+		}
+		delete(values, "max")
+	}
+	msParam := func(name string) (float64, error) {
+		d := parseDurationValue(values[name])
+		if d <= 0 {
+			return 0, fmt.Errorf("%s latency requires %s=<duration>", kind, name)
+		}
+		return durationMs(d), nil
+	}
+	floatParam := func(name string) (float64, error) {
+		f, err := strconv.ParseFloat(values[name], 64)
+		if err != nil || f <= 0 {
+			return 0, fmt.Errorf("%s latency requires %s=<positive number>", kind, name)
+		}
+		return f, nil
+	}
+
+	var err error
+	switch kind {
+	case "fixed":
+		dist.a, err = msParam("value")
+	case "normal":
+		if dist.a, err = msParam("mean"); err == nil {
+			dist.b, err = msParam("stddev")
+		}
+	case "lognormal":
+		var median float64
+		if median, err = msParam("median"); err == nil {
+			dist.a = math.Log(median)
+			dist.b, err = floatParam("sigma")
+		}
+	case "pareto":
+		if dist.a, err = msParam("min"); err == nil {
+			dist.b, err = floatParam("alpha")
+		}
+	case "percentiles":
+		dist.kind = "lognormal"
+		dist.a, dist.b, err = fitLogNormal(values)
+	default:
+		err = fmt.Errorf("unknown latency distribution %q", kind)
+	}
+	return dist, err
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// sample draws one delay from the distribution.
+func (d latencyDist) sample(rnd *lockedRand) time.Duration {
+	var ms float64
+	switch d.kind {
+	case "fixed":
+		ms = d.a
+	case "normal":
+		ms = d.a + d.b*rnd.NormFloat64()
+	case "lognormal":
+		ms = math.Exp(d.a + d.b*rnd.NormFloat64())
+	case "pareto":
+		ms = d.a / math.Pow(1-rnd.Float64(), 1/d.b)
+	}
+	delay := time.Duration(ms * float64(time.Millisecond))
+	if delay < 0 || math.IsNaN(ms) {
+		return 0
+	}
+	if delay > d.maxCap || math.IsInf(ms, 1) {
+		return d.maxCap
+	}
+	return delay
+}
+
+// percentileQuantile converts keys such as p50, p99, p999 or p99.9 to a quantile in (0, 1).
+func percentileQuantile(key string) (float64, bool) {
+	digits, ok := strings.CutPrefix(key, "p")
+	if !ok || digits == "" {
+		return 0, false
+	}
+	var q float64
+	switch {
+	case strings.Contains(digits, "."):
+		pct, err := strconv.ParseFloat(digits, 64)
+		if err != nil {
+			return 0, false
+		}
+		q = pct / 100
+	case len(digits) <= 2:
+		n, err := strconv.Atoi(digits)
+		if err != nil {
+			return 0, false
+		}
+		q = float64(n) / 100
+	case strings.HasPrefix(digits, "99"):
+		f, err := strconv.ParseFloat("0."+digits, 64)
+		if err != nil {
+			return 0, false
+		}
+		q = f
+	default:
+		return 0, false
+	}
+	return q, q > 0 && q < 1
+}
+
+// fitLogNormal fits ln(latency) = mu + sigma*z by least squares over the given
+// percentiles, where z is the standard normal quantile of each percentile.
+func fitLogNormal(values map[string]string) (mu, sigma float64, err error) {
+	type point struct{ z, y float64 }
+	var points []point
+	for key, value := range values {
+		q, ok := percentileQuantile(key)
+		if !ok {
+			return 0, 0, fmt.Errorf("invalid percentile %q", key)
+		}
+		d := parseDurationValue(value)
+		if d <= 0 {
+			return 0, 0, fmt.Errorf("invalid duration for %s: %q", key, value)
+		}
+		points = append(points, point{z: normalQuantile(q), y: math.Log(durationMs(d))})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].z < points[j].z })
+	switch len(points) {
+	case 0:
+		return 0, 0, fmt.Errorf("no percentiles given")
+	case 1:
+		if math.Abs(points[0].z) > 1e-9 {
+			return 0, 0, fmt.Errorf("a single percentile must be p50")
+		}
+		return points[0].y, 0, nil
+	}
+
+	var meanZ, meanY float64
+	for _, p := range points {
+		meanZ += p.z
+		meanY += p.y
+	}
+	meanZ /= float64(len(points))
+	meanY /= float64(len(points))
+	var cov, varZ float64
+	for _, p := range points {
+		cov += (p.z - meanZ) * (p.y - meanY)
+		varZ += (p.z - meanZ) * (p.z - meanZ)
+	}
+	if varZ == 0 {
+		return 0, 0, fmt.Errorf("percentiles must differ")
+	}
+	sigma = cov / varZ
+	if sigma < 0 {
+		return 0, 0, fmt.Errorf("percentile latencies must increase with the percentile")
+	}
+	return meanY - sigma*meanZ, sigma, nil
+}
+
+// normalQuantile returns the inverse standard normal CDF using Acklam's rational
+// approximation (relative error below 1.2e-9).
+func normalQuantile(p float64) float64 {
+	a := [6]float64{-3.969683028665376e+01, 2.209460984245205e+02, -2.759285104469687e+02, 1.383577518672690e+02, -3.066479806614716e+01, 2.506628277459239e+00}
+	b := [5]float64{-5.447609879822406e+01, 1.615858368580409e+02, -1.556989798598866e+02, 6.680131188771972e+01, -1.328068155288572e+01}
+	c := [6]float64{-7.784894002430293e-03, -3.223964580411365e-01, -2.400758277161838e+00, -2.549732539343734e+00, 4.374664141464968e+00, 2.938163982698783e+00}
+	d := [4]float64{7.784695709041462e-03, 3.224671290700398e-01, 2.445134137142996e+00, 3.754408661907416e+00}
+	const low, high = 0.02425, 1 - 0.02425
+
+	switch {
+	case p < low:
+		q := math.Sqrt(-2 * math.Log(p))
+		return (((((c[0]*q+c[1])*q+c[2])*q+c[3])*q+c[4])*q + c[5]) / ((((d[0]*q+d[1])*q+d[2])*q+d[3])*q + 1)
+	case p <= high:
+		q := p - 0.5
+		r := q * q
+		return (((((a[0]*r+a[1])*r+a[2])*r+a[3])*r+a[4])*r + a[5]) * q / (((((b[0]*r+b[1])*r+b[2])*r+b[3])*r+b[4])*r + 1)
+	default:
+		q := math.Sqrt(-2 * math.Log(1-p))
+		return -(((((c[0]*q+c[1])*q+c[2])*q+c[3])*q+c[4])*q + c[5]) / ((((d[0]*q+d[1])*q+d[2])*q+d[3])*q + 1)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+)
+
+func sampleSorted(t *testing.T, spec string, n int) []time.Duration {
+	t.Helper()
+	dist, err := parseLatencyDist(spec)
+	if err != nil {
+		t.Fatalf("parseLatencyDist(%q): %v", spec, err)
+	}
+	samples := make([]time.Duration, n)
+	withTestRNGSeed(42, func() {
+		for i := range samples {
+			samples[i] = dist.sample(rng)
+		}
+	})
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return samples
+}
+
+func within(got, want time.Duration, tolerance float64) bool {
+	return math.Abs(float64(got-want)) <= tolerance*float64(want)
+}
+
+func TestNormalQuantile(t *testing.T) {
+	cases := map[float64]float64{0.5: 0, 0.975: 1.959964, 0.01: -2.326348, 0.999: 3.090232}
+	for p, want := range cases {
+		if got := normalQuantile(p); math.Abs(got-want) > 1e-5 {
+			t.Errorf("normalQuantile(%v) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestPercentileFitMatchesTargets(t *testing.T) {
+	samples := sampleSorted(t, "p50=20ms,p99=800ms", 20000)
+	if p50 := samples[len(samples)/2]; !within(p50, 20*time.Millisecond, 0.1) {
+		t.Errorf("fitted p50 = %v, want ~20ms", p50)
+	}
+	if p99 := samples[len(samples)*99/100]; !within(p99, 800*time.Millisecond, 0.2) {
+		t.Errorf("fitted p99 = %v, want ~800ms", p99)
+	}
+}
+
+func TestParametricDistributions(t *testing.T) {
+	normal := sampleSorted(t, "normal:mean=100ms,stddev=10ms", 5000)
+	if median := normal[len(normal)/2]; !within(median, 100*time.Millisecond, 0.05) {
+		t.Errorf("normal median = %v, want ~100ms", median)
+	}
+	pareto := sampleSorted(t, "pareto:min=10ms,alpha=1.5,max=1s", 5000)
+	if pareto[0] < 10*time.Millisecond || pareto[len(pareto)-1] > time.Second {
+		t.Errorf("pareto samples outside [min, max]: %v..%v", pareto[0], pareto[len(pareto)-1])
+	}
+	lognormal := sampleSorted(t, "lognormal:median=50ms,sigma=0.5", 5000)
+	if median := lognormal[len(lognormal)/2]; !within(median, 50*time.Millisecond, 0.1) {
+		t.Errorf("lognormal median = %v, want ~50ms", median)
+	}
+
+	for _, bad := range []string{"normal:mean=10ms", "weibull:k=1", "p99=1s", "p100=1s", "p50=800ms,p99=20ms"} {
+		if _, err := parseLatencyDist(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestLatencyDistHeaderAndScenario(t *testing.T) {
+	setupTest()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Echo-Latency-Dist", "normal:mean=60ms,stddev=1ms")
+	rr := httptest.NewRecorder()
+	start := time.Now()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	if dur := time.Since(start); dur < 50*time.Millisecond || dur > 150*time.Millisecond {
+		t.Errorf("distribution delay not applied: %v", dur)
+	}
+
+	scenarios.Store("/dist", []Response{{Status: 200, Body: "ok", Delay: "fixed:40ms"}})
+	scenarioIndex.Store("/dist", 0)
+	req2, _ := http.NewRequest("GET", "/dist", nil)
+	rr2 := httptest.NewRecorder()
+	start = time.Now()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr2, req2)
+	if dur := time.Since(start); dur < 40*time.Millisecond || dur > 140*time.Millisecond {
+		t.Errorf("scenario distribution delay not applied: %v", dur)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	pathpkg "path"
 	"strings"
 	"sync"
 	"time"
@@ -17,8 +18,6 @@ import (
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 	"golang.org/x/time/rate"
-
-	mrand "math/rand"
 )
 
 //go:embed html/*
@@ -26,12 +25,13 @@ var files embed.FS
 
 // RequestRecord stores request details for history/replay
 type RequestRecord struct {
-	ID        string      `json:"id"`
-	Timestamp time.Time   `json:"timestamp"`
-	Method    string      `json:"method"`
-	URL       string      `json:"url"`
-	Headers   http.Header `json:"headers"`
-	Body      []byte      `json:"body"`
+	ID        string         `json:"id"`
+	Timestamp time.Time      `json:"timestamp"`
+	Method    string         `json:"method"`
+	URL       string         `json:"url"`
+	Headers   http.Header    `json:"headers"`
+	Body      []byte         `json:"body"`
+	Mirrors   []MirrorResult `json:"mirrors,omitempty"`
 }
 
 // Global state for metrics, counters, and scenarios
@@ -41,7 +41,7 @@ var (
 		WriteBufferSize: 1024,
 		CheckOrigin:     func(r *http.Request) bool { return true },
 	}
-	rng            = mrand.New(mrand.NewSource(time.Now().UnixNano()))
+	rng            = newLockedRand(time.Now().UnixNano())
 	requestCounter uint64
 	counterMutex   sync.Mutex
 	scenarios      sync.Map
@@ -71,9 +71,40 @@ func getHeaderOrEnv(r *http.Request, header, env string) string {
 	if val := r.Header.Get(header); val != "" {
 		return val
 	}
+	if val := ruleControl(r, header); val != "" {
+		return val
+	}
 	return os.Getenv(env)
 }
 
+// matchPath reports whether path matches pattern. A trailing "*" matches any
+// suffix (so "/api/*" covers nested paths); other patterns use path.Match globbing.
+func matchPath(pattern, path string) bool {
+	if pattern == "" || pattern == "*" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok && !strings.ContainsAny(prefix, "*?[") {
+		return strings.HasPrefix(path, prefix)
+	}
+	matched, err := pathpkg.Match(pattern, path)
+	return err == nil && matched
+}
+
+// matchRoute reports whether r matches a configured route: its path matches
+// pattern and, when methods is not empty, its method is one of them.
+func matchRoute(pattern string, methods []string, r *http.Request) bool {
+	return matchPath(pattern, r.URL.Path) && (len(methods) == 0 || containsFold(methods, r.Method))
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
 func generateRequestID() string {
 	bytes := make([]byte, 8)
 	if _, err := crand.Read(bytes); err != nil {
@@ -146,6 +177,9 @@ func main() {
 	if rateLimiter != nil {
 		wsRouter.Use(rateLimitMiddleware)
 	}
+	if config.HTTP3Port != "" {
+		wsRouter.Use(altSvcMiddleware)
+	}
 
 	mixedRouter := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/ws" || r.URL.Path == "/web-ws" {
@@ -171,6 +205,36 @@ func main() {
 	log.SetFlags(log.LstdFlags)
 	log.Printf("Advanced Echo Server starting on port %s", config.Port)
 
+	if config.GRPCPort != "" {
+		go func() {
+			if err := startGRPCServer(config.GRPCPort); err != nil {
+				log.Fatal("gRPC server failed to start:", err)
+			}
+		}()
+	}
+
+	if config.HTTP3Port != "" {
+		go func() {
+			if err := startHTTP3Server(config.HTTP3Port, router); err != nil {
+				log.Fatal("HTTP/3 server failed to start:", err)
+			}
+		}()
+	}
+	if config.TCPPort != "" {
+		go func() {
+			if err := startTCPServer(config.TCPPort); err != nil {
+				log.Fatal("TCP echo server failed to start:", err)
+			}
+		}()
+	}
+	if config.UDPPort != "" {
+		go func() {
+			if err := startUDPServer(config.UDPPort); err != nil {
+				log.Fatal("UDP echo server failed to start:", err)
+			}
+		}()
+	}
+
 	if err := startServer(server); err != nil {
 		log.Fatal("Server failed to start:", err)
 	}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Malformed response kinds for X-Echo-Malform / ECHO_MALFORM.
+const (
+	malformJSONTruncated = "json-truncated" // valid headers, JSON body cut in half
+	malformJSONInvalid   = "json-invalid"   // valid headers, syntactically invalid JSON
+	malformGzipCorrupt   = "gzip-corrupt"   // Content-Encoding: gzip over a damaged stream
+	malformLengthLong    = "length-long"    // Content-Length larger than the body, then close
+	malformLengthShort   = "length-short"   // Content-Length smaller than the bytes sent
+	malformChunked       = "chunked"        // invalid chunk size and framing
+	malformDupHeaders    = "duplicate-headers"
+	malformStatusLine    = "status-line" // unparseable status line
+	malformHeaderBytes   = "header-bytes"
+)
+
+// malformJSON renders the echoed request as a JSON document for the JSON kinds.
+func malformJSON(r *http.Request, body []byte) []byte {
+	doc, _ := json.Marshal(map[string]string{
+		"method": r.Method,
+		"path":   r.URL.Path,
+		"body":   string(body),
+	})
+	return doc
+}
+
+// processMalform writes responses that violate JSON, compression or HTTP/1.1
+// framing rules. It returns true when the request was consumed.
+func processMalform(w http.ResponseWriter, r *http.Request, body []byte) bool {
+	kind := strings.ToLower(strings.TrimSpace(getHeaderOrEnv(r, "X-Echo-Malform", "ECHO_MALFORM")))
+	if kind == "" {
+		return false
+	}
+	payload := faultPayload(r, body, 0)
+
+	// Body-level violations still travel in a well-formed HTTP response
+	switch kind {
+	case malformJSONTruncated, malformJSONInvalid:
+		doc := malformJSON(r, body)
+		if kind == malformJSONTruncated {
+			doc = doc[:len(doc)/2]
+		} else {
+			doc = append(doc[:len(doc)-1], []byte(`,'extra': undefined,}`)...)
+		}
+		log.Printf("Malform: %s JSON for %s", kind, r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(doc)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(doc)
+		chaosErrors.WithLabelValues("malform_" + strings.ReplaceAll(kind, "-", "_")).Inc()
+		return true
+
+	case malformGzipCorrupt:
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(payload)
+		gz.Close()
+		corrupt := buf.Bytes()
+		// Keep the gzip magic so clients start decoding, then damage the deflate data and trailer
+		for i := 10; i < len(corrupt); i += 3 {
+			corrupt[i] ^= 0xA5
+		}
+		log.Printf("Malform: corrupt gzip body for %s", r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Length", strconv.Itoa(len(corrupt)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(corrupt)
+		chaosErrors.WithLabelValues("malform_gzip_corrupt").Inc()
+		return true
+
+	case malformLengthLong, malformLengthShort, malformChunked, malformDupHeaders, malformStatusLine, malformHeaderBytes:
+	default:
+		return false
+	}
+
+	// Framing violations need the raw connection
+	conn, buf, ok := hijackConn(w)
+	if !ok {
+		http.Error(w, "X-Echo-Malform "+kind+" requires an HTTP/1.x connection", http.StatusNotImplemented)
+		return true
+	}
+	defer conn.Close()
+	log.Printf("Malform: writing %s response for %s", kind, r.RemoteAddr)
+	writeMalformed(buf, kind, payload)
+	buf.Flush()
+	chaosErrors.WithLabelValues("malform_" + strings.ReplaceAll(kind, "-", "_")).Inc()
+	return true
+}
+
+// writeMalformed writes one protocol-violating response of the given kind.
+func writeMalformed(buf *bufio.ReadWriter, kind string, payload []byte) {
+	const plain = "Content-Type: text/plain\r\nConnection: close\r\n"
+	switch kind {
+	case malformLengthLong:
+		fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\n%sContent-Length: %d\r\n\r\n", plain, len(payload)+100)
+		buf.Write(payload)
+	case malformLengthShort:
+		short := max(len(payload)/2, 1)
+		fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\n%sContent-Length: %d\r\n\r\n", plain, short)
+		buf.Write(payload)
+		buf.WriteString("\r\nHTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")
+	case malformChunked:
+		fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\n%sTransfer-Encoding: chunked\r\n\r\n", plain)
+		// A valid first chunk, then a non-hex size and a chunk missing its CRLF
+		fmt.Fprintf(buf, "%x\r\n%s\r\n", len(payload), payload)
+		buf.WriteString("zz\r\nnot a chunk\r\n5\r\nabcdefgh0\r\n")
+	case malformDupHeaders:
+		fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Type: application/json\r\nContent-Type: text/html\r\n"+
+			"Content-Length: %d\r\nContent-Length: %d\r\n\r\n", len(payload), len(payload)+7)
+		buf.Write(payload)
+	case malformStatusLine:
+		fmt.Fprintf(buf, "HTTP/1.1 OK 2xx\r\n%sContent-Length: %d\r\n\r\n", plain, len(payload))
+		buf.Write(payload)
+	case malformHeaderBytes:
+		fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\n%sX-Echo-Invalid: caf\xe9 \xff\xfe\x80\r\nContent-Length: %d\r\n\r\n", plain, len(payload))
+		buf.Write(payload)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rawMalformRequest sends a request over a raw TCP connection and returns the raw reply.
+func rawMalformRequest(t *testing.T, serverURL, kind string) string {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(serverURL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	io.WriteString(conn, "POST /m HTTP/1.1\r\nHost: test\r\nContent-Length: 5\r\nX-Echo-Malform: "+kind+"\r\n\r\nhello")
+	data, _ := io.ReadAll(bufio.NewReader(conn))
+	return string(data)
+}
+
+func TestMalformedJSONAndGzip(t *testing.T) {
+	setupTest()
+	for _, kind := range []string{"json-truncated", "json-invalid"} {
+		req, _ := http.NewRequest("POST", "/m", strings.NewReader(`{"a":1}`))
+		req.Header.Set("X-Echo-Malform", kind)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+		if rr.Header().Get("Content-Type") != "application/json" || json.Valid(rr.Body.Bytes()) {
+			t.Errorf("%s: expected invalid JSON labelled as JSON, got %q", kind, rr.Body.String())
+		}
+	}
+
+	req, _ := http.NewRequest("POST", "/m", strings.NewReader(strings.Repeat("compress me ", 50)))
+	req.Header.Set("X-Echo-Malform", "gzip-corrupt")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	if rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("expected Content-Encoding: gzip")
+	}
+	gz, err := gzip.NewReader(rr.Body)
+	if err == nil {
+		_, err = io.ReadAll(gz)
+	}
+	if err == nil {
+		t.Error("expected corrupt gzip stream to fail decoding")
+	}
+}
+
+func TestMalformedFraming(t *testing.T) {
+	setupTest()
+	server := newTrackedServer(t, http.HandlerFunc(echoHandler))
+	defer server.Close()
+
+	checks := map[string]func(string) bool{
+		"length-long": func(raw string) bool { return strings.Contains(raw, "Content-Length: 105\r\n") },
+		"length-short": func(raw string) bool {
+			return strings.Contains(raw, "Content-Length: 2\r\n") && strings.Contains(raw, "hello")
+		},
+		"chunked":           func(raw string) bool { return strings.Contains(raw, "chunked") && strings.Contains(raw, "zz\r\n") },
+		"duplicate-headers": func(raw string) bool { return strings.Count(raw, "Content-Length:") == 2 },
+		"status-line":       func(raw string) bool { return strings.HasPrefix(raw, "HTTP/1.1 OK 2xx\r\n") },
+		"header-bytes":      func(raw string) bool { return strings.Contains(raw, "\xff\xfe") },
+	}
+	for kind, ok := range checks {
+		if raw := rawMalformRequest(t, server.URL, kind); !ok(raw) {
+			t.Errorf("%s: unexpected raw response %q", kind, raw)
+		}
+	}
+
+	// Go's client must reject the framing violations
+	for _, kind := range []string{"chunked", "duplicate-headers", "status-line"} {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		req.Header.Set("X-Echo-Malform", kind)
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			_, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		if err == nil {
+			t.Errorf("%s: expected client error", kind)
+		}
+	}
+}
@@ -25,9 +25,52 @@ var (
 		},
 		[]string{"type"},
 	)
+	proxyFaults = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "echo_proxy_faults_total",
+			Help: "Total number of faults injected into proxied traffic",
+		},
+		[]string{"direction", "type"},
+	)
+	mirrorRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "echo_mirror_requests_total",
+			Help: "Total number of requests mirrored to shadow targets",
+		},
+		[]string{"target", "status"},
+	)
+	mirrorLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "echo_mirror_duration_seconds",
+			Help:    "Shadow target latency in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 15),
+		},
+		[]string{"target"},
+	)
+	scheduleActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "echo_schedule_active",
+			Help: "Whether each outage schedule window is currently open (1) or not (0)",
+		},
+		[]string{"schedule"},
+	)
+	rawConnections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "echo_raw_connections_total",
+			Help: "Total number of raw TCP connections accepted and UDP datagrams received",
+		},
+		[]string{"protocol"},
+	)
+	rawBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "echo_raw_bytes_total",
+			Help: "Total bytes received (in) and echoed (out) by the raw listeners",
+		},
+		[]string{"protocol", "direction"},
+	)
 )
 
 // registerPrometheusMetrics registers the collectors with the default registry.
 func registerPrometheusMetrics() {
-	prometheus.MustRegister(requestTotal, requestLatency, chaosErrors)
+	prometheus.MustRegister(requestTotal, requestLatency, chaosErrors, proxyFaults, mirrorRequests, mirrorLatency, scheduleActive, rawConnections, rawBytes)
 }
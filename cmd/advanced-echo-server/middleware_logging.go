@@ -38,7 +38,7 @@ func loggingMiddleware(next http.Handler) http.Handler {
 
 		// Request body capture (skip SSE). Capture if legacy flag or transaction logging is enabled.
 		var reqBody []byte
-		if (logBody || logTxn) && r.URL.Path != "/sse" {
+		if (logBody || logTxn) && !isStreamingPath(r.URL.Path) {
 			b, err := io.ReadAll(r.Body)
 			if err != nil {
 				log.Printf("Error reading body: %v", err)
@@ -56,7 +56,12 @@ func loggingMiddleware(next http.Handler) http.Handler {
 		}
 
 		// Wrap response writer to capture status and body
-		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		rw := &responseWriter{
+			ResponseWriter: w,
+			statusCode:     http.StatusOK,
+			capture:        ((logResp && logRespBody) || logTxn) && !isStreamingPath(r.URL.Path),
+			captureMax:     maxLogBody,
+		}
 		next.ServeHTTP(rw, r)
 
 		// Completion line
@@ -69,7 +74,7 @@ func loggingMiddleware(next http.Handler) http.Handler {
 			if logRespHeaders {
 				log.Printf("Response headers: %+v", rw.Header())
 			}
-			if logRespBody && r.URL.Path != "/sse" {
+			if logRespBody && !isStreamingPath(r.URL.Path) {
 				respBody := rw.bodyBuf.Bytes()
 				trunc := respBody
 				if maxLogBody > 0 && int64(len(trunc)) > maxLogBody {
@@ -84,7 +89,7 @@ func loggingMiddleware(next http.Handler) http.Handler {
 			log.Printf("--- transaction start ---")
 			log.Printf("REQUEST: %s %s", r.Method, r.URL.Path)
 			log.Printf("Headers: %+v", r.Header)
-			if r.URL.Path != "/sse" {
+			if !isStreamingPath(r.URL.Path) {
 				tr := reqBody
 				if maxLogBody > 0 && int64(len(tr)) > maxLogBody {
 					tr = tr[:maxLogBody]
@@ -96,7 +101,7 @@ func loggingMiddleware(next http.Handler) http.Handler {
 
 			log.Printf("RESPONSE: %d", rw.statusCode)
 			log.Printf("Headers: %+v", rw.Header())
-			if r.URL.Path != "/sse" {
+			if !isStreamingPath(r.URL.Path) {
 				respBody := rw.bodyBuf.Bytes()
 				tr2 := respBody
 				if maxLogBody > 0 && int64(len(tr2)) > maxLogBody {
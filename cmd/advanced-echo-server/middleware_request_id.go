@@ -1,6 +1,13 @@
 package main
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
+
+// generatedRequestIDKey marks requests whose X-Request-ID was made up by the
+// server rather than sent by the client.
+type generatedRequestIDKey struct{}
 
 // requestIDMiddleware ensures X-Request-ID is present and echoed back.
 func requestIDMiddleware(next http.Handler) http.Handler {
@@ -9,8 +16,18 @@ func requestIDMiddleware(next http.Handler) http.Handler {
 		if requestID == "" {
 			requestID = generateRequestID()
 			r.Header.Set("X-Request-ID", requestID)
+			r = r.WithContext(context.WithValue(r.Context(), generatedRequestIDKey{}, true))
 		}
 		w.Header().Set("X-Request-ID", requestID)
 		next.ServeHTTP(w, r)
 	})
 }
+
+// clientRequestID returns the X-Request-ID sent by the client, or "" when there
+// was none and the server generated one.
+func clientRequestID(r *http.Request) string {
+	if generated, _ := r.Context().Value(generatedRequestIDKey{}).(bool); generated {
+		return ""
+	}
+	return r.Header.Get("X-Request-ID")
+}
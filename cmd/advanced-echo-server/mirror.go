@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MirrorResult records the outcome of shadowing a request to one target.
+type MirrorResult struct {
+	Target     string    `json:"target"`
+	Status     int       `json:"status,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs float64   `json:"duration_ms"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// maxInflightMirrors bounds concurrent shadow requests; extra requests are skipped
+// rather than queued so mirroring can never back up the echo path.
+const maxInflightMirrors = 256
+
+var mirrorSlots = make(chan struct{}, maxInflightMirrors)
+
+// splitList splits a comma-separated setting, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// teeBody captures what the handler reads from the request body, up to limit bytes.
+type teeBody struct {
+	io.ReadCloser
+	buf   bytes.Buffer
+	limit int64
+}
+
+func (t *teeBody) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if room := t.limit - int64(t.buf.Len()); room > 0 {
+		t.buf.Write(p[:min(int64(n), room)])
+	}
+	return n, err
+}
+
+// mirrorMiddleware shadows sampled requests to the configured targets after the
+// echo response has been produced, so the mirror never changes what the client sees.
+// Admin endpoints, streams and protocol upgrades are never mirrored.
+func mirrorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		configLock.RLock()
+		targets := splitList(config.MirrorTargets)
+		paths := splitList(config.MirrorPaths)
+		percent := config.MirrorPercent
+		toHistory := config.MirrorHistory
+		maxBodySize := config.MaxBodySize
+		configLock.RUnlock()
+
+		if len(targets) == 0 || isAdminPath(r.URL.Path) || isStreamingPath(r.URL.Path) ||
+			r.Header.Get("Upgrade") != "" || !shouldMirror(r, paths, percent) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Capture the body as the handler consumes it so read pacing is unchanged.
+		// The body cannot be read once the handler returns, so the shadow carries
+		// what the handler read, which for echo routes is all of it up to the limit.
+		var tee *teeBody
+		if r.Body != nil {
+			tee = &teeBody{ReadCloser: r.Body, limit: maxBodySize}
+			r.Body = tee
+		}
+		shadow := r.Clone(context.Background())
+		next.ServeHTTP(w, r)
+
+		var body []byte
+		if tee != nil {
+			body = tee.buf.Bytes()
+		}
+		for _, target := range targets {
+			select {
+			case mirrorSlots <- struct{}{}:
+				go func(target string) {
+					defer func() { <-mirrorSlots }()
+					sendMirror(shadow, body, target, toHistory)
+				}(target)
+			default:
+				mirrorRequests.WithLabelValues(target, "skipped").Inc()
+			}
+		}
+	})
+}
+
+// shouldMirror applies the path filter and sampling percentage.
+func shouldMirror(r *http.Request, patterns []string, percent int) bool {
+	if len(patterns) > 0 {
+		matched := false
+		for _, p := range patterns {
+			if matchPath(p, r.URL.Path) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return rollPercent(requestRand(r), percent)
+}
+
+// sendMirror forwards one shadow request and records its status and latency.
+func sendMirror(r *http.Request, body []byte, target string, toHistory bool) {
+	start := time.Now()
+	resp, _, err := forwardRequest(r, body, target)
+	elapsed := time.Since(start)
+
+	result := MirrorResult{Target: target, DurationMs: float64(elapsed.Microseconds()) / 1000, Timestamp: start}
+	status := "error"
+	if err != nil {
+		log.Printf("Mirror to %s failed: %v", target, err)
+		result.Error = err.Error()
+	} else {
+		result.Status = resp.StatusCode
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	mirrorRequests.WithLabelValues(target, status).Inc()
+	mirrorLatency.WithLabelValues(target).Observe(elapsed.Seconds())
+
+	if toHistory {
+		recordMirrorResult(r.Header.Get("X-Request-ID"), result)
+	}
+}
+
+// recordMirrorResult attaches a shadow outcome to the matching history record, if still present.
+func recordMirrorResult(id string, result MirrorResult) {
+	if id == "" {
+		return
+	}
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+	for i := len(requestHistory) - 1; i >= 0; i-- {
+		if requestHistory[i].ID == id {
+			requestHistory[i].Mirrors = append(requestHistory[i].Mirrors, result)
+			return
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type mirroredRequest struct {
+	path string
+	body string
+}
+
+func newShadowUpstream(status int) (*httptest.Server, chan mirroredRequest) {
+	received := make(chan mirroredRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- mirroredRequest{path: r.URL.RequestURI(), body: string(body)}
+		w.WriteHeader(status)
+	}))
+	return server, received
+}
+
+// waitForMirrors blocks until in-flight shadow requests finish so they cannot
+// touch metrics after the next test resets them.
+func waitForMirrors(t *testing.T) {
+	t.Cleanup(func() {
+		// Claiming every slot can only succeed once each mirror has released its own
+		for i := 0; i < maxInflightMirrors; i++ {
+			mirrorSlots <- struct{}{}
+		}
+		for i := 0; i < maxInflightMirrors; i++ {
+			<-mirrorSlots
+		}
+	})
+}
+
+func TestMirrorShadowsRequestAndRecordsHistory(t *testing.T) {
+	setupTest()
+	waitForMirrors(t)
+	shadow, received := newShadowUpstream(http.StatusTeapot)
+	defer shadow.Close()
+	configLock.Lock()
+	config.MirrorTargets = shadow.URL
+	config.MirrorPercent = 100
+	config.MirrorHistory = true
+	configLock.Unlock()
+	router := setupRoutes()
+
+	req, _ := http.NewRequest("POST", "/orders?x=1", strings.NewReader(`{"id":7}`))
+	req.Header.Set("X-Request-ID", "mirror-1")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != `{"id":7}` {
+		t.Fatalf("echo response changed by mirroring: %d %q", rr.Code, rr.Body.String())
+	}
+
+	select {
+	case got := <-received:
+		if got.path != "/orders?x=1" || got.body != `{"id":7}` {
+			t.Errorf("unexpected mirrored request: %+v", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("shadow target never received the request")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		historyMutex.Lock()
+		var mirrors []MirrorResult
+		if len(requestHistory) > 0 {
+			mirrors = requestHistory[len(requestHistory)-1].Mirrors
+		}
+		historyMutex.Unlock()
+		if len(mirrors) == 1 {
+			if mirrors[0].Status != http.StatusTeapot || mirrors[0].Target != shadow.URL {
+				t.Errorf("unexpected mirror result: %+v", mirrors[0])
+			}
+			if v := testutil.ToFloat64(mirrorRequests.WithLabelValues(shadow.URL, "418")); v != 1 {
+				t.Errorf("expected mirror metric 1, got %v", v)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("mirror result never recorded in history")
+}
+
+func TestMirrorPathFilter(t *testing.T) {
+	setupTest()
+	waitForMirrors(t)
+	shadow, received := newShadowUpstream(http.StatusOK)
+	defer shadow.Close()
+	configLock.Lock()
+	config.MirrorTargets = shadow.URL
+	config.MirrorPaths = "/api/*"
+	config.MirrorPercent = 100
+	configLock.Unlock()
+	router := setupRoutes()
+
+	for _, path := range []string{"/other", "/api/v1/items"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	select {
+	case got := <-received:
+		if got.path != "/api/v1/items" {
+			t.Errorf("filtered path was mirrored: %s", got.path)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("matching path was not mirrored")
+	}
+	select {
+	case got := <-received:
+		t.Errorf("unexpected extra mirror: %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMirrorSkipsAdminAndUpgrades(t *testing.T) {
+	setupTest()
+	waitForMirrors(t)
+	shadow, received := newShadowUpstream(http.StatusOK)
+	defer shadow.Close()
+	configLock.Lock()
+	config.MirrorTargets = shadow.URL
+	config.MirrorPercent = 100
+	configLock.Unlock()
+	router := setupRoutes()
+
+	upgrade, _ := http.NewRequest("GET", "/chat", nil)
+	upgrade.Header.Set("Connection", "Upgrade")
+	upgrade.Header.Set("Upgrade", "websocket")
+	admin, _ := http.NewRequest("GET", "/info", nil)
+	echo, _ := http.NewRequest("GET", "/echo", nil)
+	for _, req := range []*http.Request{upgrade, admin, echo} {
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	select {
+	case got := <-received:
+		if got.path != "/echo" {
+			t.Errorf("admin or upgrade request was mirrored: %s", got.path)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("echo request was not mirrored")
+	}
+	select {
+	case got := <-received:
+		t.Errorf("unexpected extra mirror: %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
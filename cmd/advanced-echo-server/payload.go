@@ -0,0 +1,277 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Generated payloads are produced on demand through io.ReaderAt, so arbitrarily
+// large bodies stream in constant memory and any byte range can be served.
+
+// lineWidth is the length of each generated text line, newline included.
+const lineWidth = 64
+
+// splitmix64 is a counter-based mixer: word i of a stream is splitmix64(seed + i),
+// which makes random bytes addressable at any offset.
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// generatedPayload is a deterministic body of a known size.
+type generatedPayload interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// randomPayload yields pseudo-random bytes derived from seed.
+type randomPayload struct {
+	seed uint64
+	size int64
+}
+
+func (p randomPayload) Size() int64 { return p.size }
+
+func (p randomPayload) ReadAt(b []byte, off int64) (int, error) {
+	if off >= p.size {
+		return 0, io.EOF
+	}
+	n := int(min(int64(len(b)), p.size-off))
+	var word [8]byte
+	for i := 0; i < n; {
+		pos := off + int64(i)
+		binary.LittleEndian.PutUint64(word[:], splitmix64(p.seed+uint64(pos/8)))
+		i += copy(b[i:n], word[pos%8:])
+	}
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// repeatPayload repeats a string until size bytes have been produced.
+type repeatPayload struct {
+	pattern []byte
+	size    int64
+}
+
+func (p repeatPayload) Size() int64 { return p.size }
+
+func (p repeatPayload) ReadAt(b []byte, off int64) (int, error) {
+	if off >= p.size {
+		return 0, io.EOF
+	}
+	n := int(min(int64(len(b)), p.size-off))
+	for i := 0; i < n; {
+		start := (off + int64(i)) % int64(len(p.pattern))
+		i += copy(b[i:n], p.pattern[start:])
+	}
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// recordPayload is a prefix followed by count fixed-width records, such as the
+// items of a JSON array or the lines of a text file.
+type recordPayload struct {
+	prefix string
+	count  int64
+	width  int
+	render func(i int64) string // must return exactly width bytes
+}
+
+func (p recordPayload) Size() int64 { return int64(len(p.prefix)) + p.count*int64(p.width) }
+
+func (p recordPayload) ReadAt(b []byte, off int64) (int, error) {
+	size := p.Size()
+	if off >= size {
+		return 0, io.EOF
+	}
+	n := int(min(int64(len(b)), size-off))
+	for i := 0; i < n; {
+		pos := off + int64(i)
+		if pos < int64(len(p.prefix)) {
+			i += copy(b[i:n], p.prefix[pos:])
+			continue
+		}
+		rel := pos - int64(len(p.prefix))
+		record := p.render(rel / int64(p.width))
+		i += copy(b[i:n], record[rel%int64(p.width):])
+	}
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// jsonItemsPayload renders a valid JSON array of count objects. Numbers are
+// space-padded so every item has the same width.
+func jsonItemsPayload(count int64) recordPayload {
+	item := func(i int64) string {
+		return fmt.Sprintf(`{"id":%10d,"name":"item-%010d","hash":"%016x"}`, i, i, splitmix64(uint64(i)))
+	}
+	width := len(item(0)) + 1
+	if count == 0 {
+		return recordPayload{prefix: "[]", width: width}
+	}
+	return recordPayload{prefix: "[", count: count, width: width, render: func(i int64) string {
+		if i == count-1 {
+			return item(i) + "]"
+		}
+		return item(i) + ","
+	}}
+}
+
+// linesPayload renders count newline-terminated text lines.
+func linesPayload(count int64) recordPayload {
+	const filler = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	return recordPayload{count: count, width: lineWidth, render: func(i int64) string {
+		head := fmt.Sprintf("line %010d ", i)
+		shift := int(i % int64(len(filler)))
+		tail := (filler[shift:] + filler)[:lineWidth-len(head)-1]
+		return head + tail + "\n"
+	}}
+}
+
+// generatedPayloadFor builds the body requested by X-Echo-Response-Size and
+// X-Echo-Payload-Pattern / ECHO_PAYLOAD_PATTERN. Patterns:
+//
+//	random          per-request random bytes (replayable via X-Echo-Seed)
+//	seeded[:N]      the same bytes for every request with seed N
+//	repeat:<text>   text repeated to the requested size
+//	json[:N]        a JSON array of N items (or as many as fit the size)
+//	lines[:N]       N text lines (or as many as fit the size)
+//
+// It returns false when no generated payload was requested.
+func generatedPayloadFor(r *http.Request) (generatedPayload, string, bool) {
+	size := int64(-1)
+	if sizeHeader := r.Header.Get("X-Echo-Response-Size"); sizeHeader != "" {
+		if n, err := strconv.ParseInt(sizeHeader, 10, 64); err == nil {
+			size = n
+		} else if n := parseByteSize(sizeHeader); n > 0 {
+			size = n
+		}
+	}
+	spec := getHeaderOrEnv(r, "X-Echo-Payload-Pattern", "ECHO_PAYLOAD_PATTERN")
+	kind, arg, _ := strings.Cut(spec, ":")
+	kind = strings.ToLower(strings.TrimSpace(kind))
+
+	count := func(overhead, width int64) (int64, bool) {
+		if n, err := strconv.ParseInt(strings.TrimSpace(arg), 10, 64); err == nil && n >= 0 {
+			return n, true
+		}
+		if size > overhead {
+			return max((size-overhead)/width, 1), true
+		}
+		return 0, false
+	}
+
+	switch kind {
+	case "json":
+		n, ok := count(1, int64(jsonItemsPayload(1).width))
+		return jsonItemsPayload(n), "application/json", ok
+	case "lines":
+		n, ok := count(0, lineWidth)
+		return linesPayload(n), "text/plain", ok
+	}
+	if size <= 0 {
+		return nil, "", false
+	}
+	switch kind {
+	case "repeat":
+		if arg == "" {
+			arg = "echo"
+		}
+		return repeatPayload{pattern: []byte(arg), size: size}, "text/plain", true
+	case "seeded":
+		seed, _ := strconv.ParseUint(strings.TrimSpace(arg), 10, 64)
+		return randomPayload{seed: seed, size: size}, "application/octet-stream", true
+	default:
+		return randomPayload{seed: uint64(requestRand(r).Int63()), size: size}, "application/octet-stream", true
+	}
+}
+
+// payloadChecksum hashes the whole payload in a streaming pre-pass so the digest
+// can be sent as a header ahead of the body.
+func payloadChecksum(p generatedPayload) string {
+	h := sha256.New()
+	io.Copy(h, io.NewSectionReader(p, 0, p.Size()))
+	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+}
+
+// payloadETag derives a validator from the parameters that define the payload,
+// so it costs nothing to compute whatever the size.
+func payloadETag(p generatedPayload) string {
+	var key string
+	switch p := p.(type) {
+	case randomPayload:
+		key = fmt.Sprintf("random:%d:%d", p.seed, p.size)
+	case repeatPayload:
+		key = fmt.Sprintf("repeat:%q:%d", p.pattern, p.size)
+	case recordPayload:
+		key = fmt.Sprintf("records:%q:%d:%d", p.prefix, p.count, p.width)
+		if p.count > 0 {
+			key += ":" + p.render(0)
+		}
+	}
+	sum := sha256.Sum256([]byte(key))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// checksumMode returns the X-Echo-Checksum / ECHO_CHECKSUM setting: "header"
+// hashes the payload before sending it, "trailer" hashes it while streaming.
+// Anything else sends no checksum.
+func checksumMode(r *http.Request) string {
+	return strings.ToLower(strings.TrimSpace(getHeaderOrEnv(r, "X-Echo-Checksum", "ECHO_CHECKSUM")))
+}
+
+// hashingReader hashes what is read through it. Seeking is passed through for
+// conditional requests, which are never hashed.
+type hashingReader struct {
+	io.ReadSeeker
+	hash hash.Hash
+	n    int64
+}
+
+func (h *hashingReader) Read(b []byte) (int, error) {
+	n, err := h.ReadSeeker.Read(b)
+	h.hash.Write(b[:n])
+	h.n += int64(n)
+	return n, err
+}
+
+// writeGeneratedPayload streams a generated body, honoring body pacing, ranges
+// and conditional requests. Checksums are opt-in and only cover full responses.
+func writeGeneratedPayload(w http.ResponseWriter, r *http.Request, p generatedPayload, contentType string) {
+	w.Header().Set("Content-Type", contentType)
+	var content io.ReadSeeker = io.NewSectionReader(p, 0, p.Size())
+	full := !hasConditionalHeaders(r)
+	switch checksumMode(r) {
+	case "header":
+		if full {
+			w.Header().Set("X-Echo-Checksum", payloadChecksum(p))
+		}
+	case "trailer":
+		if full {
+			hashed := &hashingReader{ReadSeeker: content, hash: sha256.New()}
+			content = hashed
+			w.Header().Add("Trailer", "X-Echo-Checksum")
+			defer func() {
+				if hashed.n == p.Size() {
+					w.Header().Set("X-Echo-Checksum", "sha256="+hex.EncodeToString(hashed.hash.Sum(nil)))
+				}
+			}()
+		}
+	}
+	serveEntity(w, r, content, p.Size(), payloadETag(p), startTime, bodyShapeFor(r, Response{}))
+}
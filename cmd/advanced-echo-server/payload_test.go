@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGeneratedPayloadRandomAccess(t *testing.T) {
+	payloads := map[string]generatedPayload{
+		"random": randomPayload{seed: 7, size: 1000},
+		"repeat": repeatPayload{pattern: []byte("abc"), size: 1000},
+		"json":   jsonItemsPayload(12),
+		"lines":  linesPayload(12),
+	}
+	for name, p := range payloads {
+		full, err := io.ReadAll(io.NewSectionReader(p, 0, p.Size()))
+		if err != nil || int64(len(full)) != p.Size() {
+			t.Fatalf("%s: read %d of %d bytes: %v", name, len(full), p.Size(), err)
+		}
+		for _, off := range []int64{0, 1, 7, 9, 63, 500, p.Size() - 3} {
+			part := make([]byte, 13)
+			n, _ := p.ReadAt(part, off)
+			if !bytes.Equal(part[:n], full[off:min(off+13, p.Size())]) {
+				t.Errorf("%s: ReadAt(%d) disagrees with the sequential stream", name, off)
+			}
+		}
+	}
+}
+
+func TestGeneratedPayloadPatterns(t *testing.T) {
+	setupTest()
+	serve := func(headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/download", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve(map[string]string{"X-Echo-Payload-Pattern": "json:25"})
+	var items []map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &items); err != nil || len(items) != 25 {
+		t.Errorf("json:25 produced %d items: %v", len(items), err)
+	}
+	rr = serve(map[string]string{"X-Echo-Payload-Pattern": "json", "X-Echo-Response-Size": "2k"})
+	if !json.Valid(rr.Body.Bytes()) || rr.Body.Len() > 2048 {
+		t.Errorf("sized json should be valid and fit 2k, got %d bytes", rr.Body.Len())
+	}
+
+	rr = serve(map[string]string{"X-Echo-Payload-Pattern": "lines:10"})
+	if lines := strings.Split(strings.TrimSuffix(rr.Body.String(), "\n"), "\n"); len(lines) != 10 || len(lines[3]) != lineWidth-1 {
+		t.Errorf("lines:10 produced %d lines", len(lines))
+	}
+
+	rr = serve(map[string]string{"X-Echo-Payload-Pattern": "repeat:ab", "X-Echo-Response-Size": "5"})
+	if rr.Body.String() != "ababa" {
+		t.Errorf("repeat produced %q", rr.Body.String())
+	}
+
+	first := serve(map[string]string{"X-Echo-Payload-Pattern": "seeded:9", "X-Echo-Response-Size": "4k", "X-Echo-Checksum": "header"})
+	second := serve(map[string]string{"X-Echo-Payload-Pattern": "seeded:9", "X-Echo-Response-Size": "4k"})
+	random := serve(map[string]string{"X-Echo-Response-Size": "4k", "X-Echo-Checksum": "header"})
+	if !bytes.Equal(first.Body.Bytes(), second.Body.Bytes()) || bytes.Equal(first.Body.Bytes(), random.Body.Bytes()) {
+		t.Error("seeded payloads should repeat and differ from random ones")
+	}
+	if first.Header().Get("ETag") != second.Header().Get("ETag") || first.Header().Get("ETag") == random.Header().Get("ETag") {
+		t.Error("ETags should follow the payload parameters")
+	}
+	if second.Header().Get("X-Echo-Checksum") != "" {
+		t.Error("checksums should be opt-in")
+	}
+
+	for _, rr := range []*httptest.ResponseRecorder{first, random} {
+		sum := sha256.Sum256(rr.Body.Bytes())
+		if got := rr.Header().Get("X-Echo-Checksum"); got != "sha256="+hex.EncodeToString(sum[:]) {
+			t.Errorf("checksum header %q does not match body", got)
+		}
+		if rr.Header().Get("Content-Length") != "4096" {
+			t.Errorf("expected Content-Length 4096, got %q", rr.Header().Get("Content-Length"))
+		}
+	}
+
+	rr = serve(map[string]string{"X-Echo-Response-Size": "4k", "X-Echo-Checksum": "trailer"})
+	sum := sha256.Sum256(rr.Body.Bytes())
+	if got := rr.Result().Trailer.Get("X-Echo-Checksum"); got != "sha256="+hex.EncodeToString(sum[:]) {
+		t.Errorf("checksum trailer %q does not match body", got)
+	}
+	if rr.Header().Get("Content-Length") != "" {
+		t.Error("a checksum trailer needs chunked encoding")
+	}
+
+	ranged := serve(map[string]string{"X-Echo-Response-Size": "4k", "X-Echo-Checksum": "header", "Range": "bytes=0-99"})
+	if ranged.Code != http.StatusPartialContent || ranged.Header().Get("X-Echo-Checksum") != "" {
+		t.Errorf("range requests should not be hashed, got %d", ranged.Code)
+	}
+}
+
+// countingWriter discards the body while counting it.
+type countingWriter struct {
+	header http.Header
+	n      int64
+}
+
+func (c *countingWriter) Header() http.Header         { return c.header }
+func (c *countingWriter) WriteHeader(int)             {}
+func (c *countingWriter) Write(p []byte) (int, error) { c.n += int64(len(p)); return len(p), nil }
+
+func TestGeneratedPayloadStreamsWithoutBuffering(t *testing.T) {
+	setupTest()
+	configLock.Lock()
+	config.HistorySize = 0
+	configLock.Unlock()
+	const size = 64 << 20
+	req, _ := http.NewRequest("GET", "/big", nil)
+	req.Header.Set("X-Echo-Response-Size", "64m")
+	w := &countingWriter{header: http.Header{}}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	echoHandler(w, req)
+	runtime.ReadMemStats(&after)
+	if w.n != size {
+		t.Fatalf("streamed %d bytes, want %d", w.n, size)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > size/4 {
+		t.Errorf("streaming allocated %d bytes for a %d byte body", allocated, size)
+	}
+}
+
+func TestGeneratedPayloadThroughRouterStaysSmall(t *testing.T) {
+	setupTest()
+	configLock.Lock()
+	config.HistorySize = 0
+	config.LogResponse = true
+	config.LogResponseBody = true
+	config.MaxLogBodySize = 2048
+	configLock.Unlock()
+	const size = 64 << 20
+	req, _ := http.NewRequest("GET", "/big", nil)
+	req.Header.Set("X-Echo-Response-Size", "64m")
+	w := &countingWriter{header: http.Header{}}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	setupRoutes().ServeHTTP(w, req)
+	runtime.ReadMemStats(&after)
+	if w.n != size {
+		t.Fatalf("streamed %d bytes, want %d", w.n, size)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > size/4 {
+		t.Errorf("the middleware chain allocated %d bytes for a %d byte body", allocated, size)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ProxyFaultRule applies faults to proxied requests whose path (and optionally method) matches.
+type ProxyFaultRule struct {
+	Path     string     `yaml:"path" json:"path"`
+	Methods  []string   `yaml:"methods,omitempty" json:"methods,omitempty"`
+	Request  ProxyFault `yaml:"request,omitempty" json:"request,omitempty"`
+	Response ProxyFault `yaml:"response,omitempty" json:"response,omitempty"`
+}
+
+// ProxyFault describes the faults injected in one direction of a proxied exchange.
+// On the request side faults happen before the upstream is contacted; on the
+// response side the upstream has already handled the request.
+type ProxyFault struct {
+	Delay     string `yaml:"delay,omitempty" json:"delay,omitempty"`
+	Error     int    `yaml:"error,omitempty" json:"error,omitempty"`
+	ErrorRate int    `yaml:"error_rate,omitempty" json:"error_rate,omitempty"`
+	Truncate  int    `yaml:"truncate,omitempty" json:"truncate,omitempty"`
+	DropRate  int    `yaml:"drop_rate,omitempty" json:"drop_rate,omitempty"`
+}
+
+var (
+	proxyFaultRules []ProxyFaultRule
+	proxyFaultMutex sync.RWMutex
+)
+
+// loadProxyFaults reads fault rules from a YAML file.
+func loadProxyFaults(path string) {
+	if rules, ok := loadConfigList[ProxyFaultRule](path, "proxy fault"); ok {
+		setProxyFaults(rules)
+	}
+}
+
+// setProxyFaults installs the proxy fault rules.
+func setProxyFaults(rules []ProxyFaultRule) error {
+	proxyFaultMutex.Lock()
+	proxyFaultRules = rules
+	proxyFaultMutex.Unlock()
+	return nil
+}
+
+// proxyFaultsHandler lists (GET) or replaces (POST) the proxy fault rules at runtime.
+func proxyFaultsHandler(w http.ResponseWriter, r *http.Request) {
+	configListHandler(w, r, "proxy faults", func() []ProxyFaultRule {
+		proxyFaultMutex.RLock()
+		defer proxyFaultMutex.RUnlock()
+		return proxyFaultRules
+	}, setProxyFaults)
+}
+
+// matchProxyFault returns the first rule matching the request, if any.
+func matchProxyFault(r *http.Request) (ProxyFaultRule, bool) {
+	proxyFaultMutex.RLock()
+	defer proxyFaultMutex.RUnlock()
+	for _, rule := range proxyFaultRules {
+		if matchRoute(rule.Path, rule.Methods, r) {
+			return rule, true
+		}
+	}
+	return ProxyFaultRule{}, false
+}
+
+// apply injects delay, drop and error faults for one direction. It returns true
+// when the exchange was terminated and nothing more must be written.
+func (f ProxyFault) apply(w http.ResponseWriter, r *http.Request, direction string) bool {
+	rnd := requestRand(r)
+	if delay, ok := parseDelaySpec(f.Delay, rnd); ok {
+		log.Printf("Proxy %s delay: %v for %s", direction, delay, r.URL.Path)
+		proxyFaults.WithLabelValues(direction, "delay").Inc()
+		time.Sleep(delay)
+	}
+	if rollPercent(rnd, f.DropRate) {
+		log.Printf("Proxy %s fault: dropping connection for %s", direction, r.URL.Path)
+		proxyFaults.WithLabelValues(direction, "drop").Inc()
+		dropConnection(w)
+		return true
+	}
+	if f.Error >= 100 && f.Error <= 599 {
+		rate := f.ErrorRate
+		if rate == 0 {
+			rate = 100
+		}
+		if rollPercent(rnd, rate) {
+			log.Printf("Proxy %s fault: substituting %d for %s", direction, f.Error, r.URL.Path)
+			proxyFaults.WithLabelValues(direction, "error").Inc()
+			w.Header().Set("X-Echo-Proxy-Fault", direction+"-error")
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(f.Error)
+			w.Write([]byte(fmt.Sprintf("Proxy fault injection: %d", f.Error)))
+			return true
+		}
+	}
+	return false
+}
+
+// processProxy forwards the request to the configured upstream, injecting any
+// matching faults. It returns false when proxy mode is disabled.
+func processProxy(w http.ResponseWriter, r *http.Request, body []byte) bool {
+	configLock.RLock()
+	upstream := config.ProxyUpstream
+	maxBodySize := config.MaxBodySize
+	configLock.RUnlock()
+	if upstream == "" {
+		return false
+	}
+
+	// The echo handler stops reading at MAX_BODY_SIZE; forwarding the rest of a
+	// cut-off body would hand the upstream a request the client never sent
+	if int64(len(body)) >= maxBodySize && r.ContentLength != int64(len(body)) {
+		http.Error(w, fmt.Sprintf("Request body exceeds the proxy limit of %d bytes", maxBodySize), http.StatusRequestEntityTooLarge)
+		return true
+	}
+
+	rule, hasRule := matchProxyFault(r)
+	length := int64(len(body))
+	truncated := false
+	if hasRule {
+		if rule.Request.apply(w, r, "request") {
+			return true
+		}
+		if rule.Request.Truncate > 0 && rule.Request.Truncate < len(body) {
+			// Declare the full length but send only part of it, so the upstream sees a short read
+			proxyFaults.WithLabelValues("request", "truncate").Inc()
+			body = body[:rule.Request.Truncate]
+			truncated = true
+		}
+	}
+
+	var resp *http.Response
+	var err error
+	if truncated {
+		w.Header().Set("X-Echo-Proxy-Fault", "request-truncate")
+		resp, err = sendTruncated(r, body, length, upstream)
+	} else {
+		resp, err = sendUpstream(r, body, upstream)
+	}
+	if err != nil {
+		http.Error(w, "Upstream request failed: "+err.Error(), http.StatusBadGateway)
+		return true
+	}
+	defer resp.Body.Close()
+
+	if hasRule && rule.Response.apply(w, r, "response") {
+		return true
+	}
+
+	copyResponseHeaders(w, resp.Header)
+	if hasRule && rule.Response.Truncate > 0 {
+		// Advertise the full length but send only part of it, so the client sees a
+		// short body. This needs the whole body to know its length.
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			http.Error(w, "Upstream response failed: "+err.Error(), http.StatusBadGateway)
+			return true
+		}
+		if rule.Response.Truncate < len(respBody) {
+			proxyFaults.WithLabelValues("response", "truncate").Inc()
+			w.Header().Set("X-Echo-Proxy-Fault", "response-truncate")
+			w.Header().Set("Content-Length", strconv.Itoa(len(respBody)))
+			respBody = respBody[:rule.Response.Truncate]
+		}
+		w.WriteHeader(resp.StatusCode)
+		w.Write(respBody)
+		return true
+	}
+
+	// Stream everything else as it arrives
+	if resp.ContentLength >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(resp.ContentLength, 10))
+	}
+	w.WriteHeader(resp.StatusCode)
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				break
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	return true
+}
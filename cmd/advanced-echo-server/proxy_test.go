@@ -0,0 +1,229 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newCountingUpstream(hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		w.Write([]byte("0123456789"))
+	}))
+}
+
+func setProxyUpstream(upstream string) {
+	configLock.Lock()
+	config.ProxyUpstream = upstream
+	configLock.Unlock()
+}
+
+func TestProxyForwardsWithoutFaults(t *testing.T) {
+	setupTest()
+	var hits int32
+	upstream := newCountingUpstream(&hits)
+	defer upstream.Close()
+	setProxyUpstream(upstream.URL)
+
+	req, _ := http.NewRequest("GET", "/clean", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "0123456789" {
+		t.Fatalf("unexpected proxied response: %d %q", rr.Code, rr.Body.String())
+	}
+	if atomic.LoadInt32(&hits) != 1 {
+		t.Errorf("expected 1 upstream hit, got %d", hits)
+	}
+}
+
+func TestProxyRequestAndResponseFaults(t *testing.T) {
+	setupTest()
+	var hits int32
+	upstream := newCountingUpstream(&hits)
+	defer upstream.Close()
+	setProxyUpstream(upstream.URL)
+	router := setupRoutes()
+
+	rules := `[
+		{"path":"/blocked/*","request":{"error":503}},
+		{"path":"/flaky","methods":["POST"],"response":{"error":502}},
+		{"path":"/short","response":{"truncate":4,"delay":"30ms"}}
+	]`
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/proxy/faults", strings.NewReader(rules))
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("post faults status: %d", rr.Code)
+	}
+
+	// Request-side error never reaches the upstream
+	rr1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("GET", "/blocked/a/b", nil)
+	router.ServeHTTP(rr1, req1)
+	if rr1.Code != http.StatusServiceUnavailable || rr1.Header().Get("X-Echo-Proxy-Fault") != "request-error" {
+		t.Errorf("expected request-side 503, got %d %v", rr1.Code, rr1.Header())
+	}
+	if atomic.LoadInt32(&hits) != 0 {
+		t.Errorf("upstream should not be contacted, got %d hits", hits)
+	}
+
+	// Response-side error only applies to the matching method, after the upstream saw it
+	rr2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("POST", "/flaky", strings.NewReader("x"))
+	router.ServeHTTP(rr2, req2)
+	if rr2.Code != http.StatusBadGateway {
+		t.Errorf("expected response-side 502, got %d", rr2.Code)
+	}
+	rr3 := httptest.NewRecorder()
+	req3, _ := http.NewRequest("GET", "/flaky", nil)
+	router.ServeHTTP(rr3, req3)
+	if rr3.Code != http.StatusOK {
+		t.Errorf("GET /flaky should pass through, got %d", rr3.Code)
+	}
+	if atomic.LoadInt32(&hits) != 2 {
+		t.Errorf("expected 2 upstream hits, got %d", hits)
+	}
+
+	// Truncated body keeps the full Content-Length
+	rr4 := httptest.NewRecorder()
+	req4, _ := http.NewRequest("GET", "/short", nil)
+	start := time.Now()
+	router.ServeHTTP(rr4, req4)
+	if time.Since(start) < 30*time.Millisecond {
+		t.Errorf("response delay not applied")
+	}
+	if rr4.Body.String() != "0123" || rr4.Header().Get("Content-Length") != "10" {
+		t.Errorf("unexpected truncation: body=%q content-length=%q", rr4.Body.String(), rr4.Header().Get("Content-Length"))
+	}
+}
+
+func TestProxyDropConnection(t *testing.T) {
+	setupTest()
+	var hits int32
+	upstream := newCountingUpstream(&hits)
+	defer upstream.Close()
+	setProxyUpstream(upstream.URL)
+	proxyFaultRules = []ProxyFaultRule{{Path: "/drop", Response: ProxyFault{DropRate: 100}}}
+
+	server := newTrackedServer(t, setupRoutes())
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/drop")
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("expected dropped connection, got status %d", resp.StatusCode)
+	}
+	if atomic.LoadInt32(&hits) != 1 {
+		t.Errorf("response-side drop should reach upstream once, got %d", hits)
+	}
+}
+
+func TestProxyRequestTruncateIsShortRead(t *testing.T) {
+	setupTest()
+	type upload struct {
+		length int64
+		body   string
+		err    error
+	}
+	received := make(chan upload, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		received <- upload{r.ContentLength, string(body), err}
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer upstream.Close()
+	setProxyUpstream(upstream.URL)
+	proxyFaultRules = []ProxyFaultRule{{Path: "/upload", Request: ProxyFault{Truncate: 4}}}
+
+	req, _ := http.NewRequest("POST", "/upload", strings.NewReader("0123456789"))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+
+	got := <-received
+	if got.length != 10 || got.body != "0123" || got.err == nil {
+		t.Errorf("upstream should see Content-Length 10 and a short read, got %+v", got)
+	}
+	if rr.Code != http.StatusBadRequest || rr.Header().Get("X-Echo-Proxy-Fault") != "request-truncate" {
+		t.Errorf("expected the upstream's answer with a request-truncate fault, got %d %v", rr.Code, rr.Header())
+	}
+}
+
+func TestProxyTruncatedRequestSkipsInterimResponses(t *testing.T) {
+	setupTest()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer upstream.Close()
+	setProxyUpstream(upstream.URL)
+	proxyFaultRules = []ProxyFaultRule{{Path: "/upload", Request: ProxyFault{Truncate: 4}}}
+
+	req, _ := http.NewRequest("POST", "/upload", strings.NewReader("0123456789"))
+	req.Header.Set("Expect", "100-continue")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected the upstream's final answer, got %d", rr.Code)
+	}
+}
+
+func TestProxyRejectsOversizedBody(t *testing.T) {
+	setupTest()
+	var hits int32
+	upstream := newCountingUpstream(&hits)
+	defer upstream.Close()
+	setProxyUpstream(upstream.URL)
+	configLock.Lock()
+	config.MaxBodySize = 8
+	configLock.Unlock()
+
+	req, _ := http.NewRequest("POST", "/upload", strings.NewReader("0123456789"))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for a body over the limit, got %d", rr.Code)
+	}
+	if atomic.LoadInt32(&hits) != 0 {
+		t.Errorf("a cut-off body must not be forwarded, got %d upstream hits", hits)
+	}
+}
+
+func TestProxyStreamsResponse(t *testing.T) {
+	setupTest()
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first"))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte("second"))
+	}))
+	defer upstream.Close()
+	setProxyUpstream(upstream.URL)
+	server := httptest.NewServer(setupRoutes())
+	defer server.Close()
+	defer close(release)
+
+	resp, err := http.Get(server.URL + "/feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	got := make(chan string, 1)
+	go func() {
+		buf := make([]byte, 5)
+		io.ReadFull(resp.Body, buf)
+		got <- string(buf)
+	}()
+	select {
+	case first := <-got:
+		if first != "first" {
+			t.Errorf("expected the first chunk, got %q", first)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the first chunk was held back until the upstream finished")
+	}
+}
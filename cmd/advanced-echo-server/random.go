@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	mrand "math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// lockedRand is a math/rand generator that is safe for concurrent use.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *mrand.Rand
+}
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{r: mrand.New(mrand.NewSource(seed))}
+}
+
+func (l *lockedRand) Seed(seed int64) {
+	l.mu.Lock()
+	l.r.Seed(seed)
+	l.mu.Unlock()
+}
+
+func (l *lockedRand) Intn(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Intn(n)
+}
+
+func (l *lockedRand) Int63() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Int63()
+}
+
+func (l *lockedRand) Float64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Float64()
+}
+
+func (l *lockedRand) NormFloat64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.NormFloat64()
+}
+
+func (l *lockedRand) Read(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Read(p)
+}
+
+type requestRandKey struct{}
+
+// withRequestRand attaches a per-request generator to r. The seed comes from
+// X-Echo-Seed or, failing that, from the global generator (itself seeded by
+// ECHO_SEED), and is reported back in the X-Echo-Seed response header so a run
+// can be replayed exactly.
+func withRequestRand(w http.ResponseWriter, r *http.Request) *http.Request {
+	seed, err := strconv.ParseInt(r.Header.Get("X-Echo-Seed"), 10, 64)
+	if err != nil {
+		seed = rng.Int63()
+	}
+	w.Header().Set("X-Echo-Seed", strconv.FormatInt(seed, 10))
+	return r.WithContext(context.WithValue(r.Context(), requestRandKey{}, newLockedRand(seed)))
+}
+
+// requestRand returns the request's generator, or the global one if none is attached.
+func requestRand(r *http.Request) *lockedRand {
+	if rnd, ok := r.Context().Value(requestRandKey{}).(*lockedRand); ok {
+		return rnd
+	}
+	return rng
+}
+
+// rollPercent returns true with the given percent probability.
+func rollPercent(rnd *lockedRand, percent int) bool {
+	return percent >= 100 || (percent > 0 && rnd.Intn(100) < percent)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func seededRequest(t *testing.T, seed string, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req, _ := http.NewRequest("POST", "/", bytes.NewReader([]byte("x")))
+	if seed != "" {
+		req.Header.Set("X-Echo-Seed", seed)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRequestSeedReplaysExactly(t *testing.T) {
+	setupTest()
+	payload := map[string]string{"X-Echo-Response-Size": "256"}
+	first := seededRequest(t, "1234", payload)
+	second := seededRequest(t, "1234", payload)
+	if !bytes.Equal(first.Body.Bytes(), second.Body.Bytes()) {
+		t.Error("same X-Echo-Seed produced different payloads")
+	}
+	if first.Header().Get("X-Echo-Seed") != "1234" {
+		t.Errorf("seed not reported back: %q", first.Header().Get("X-Echo-Seed"))
+	}
+	other := seededRequest(t, "99", payload)
+	if bytes.Equal(first.Body.Bytes(), other.Body.Bytes()) {
+		t.Error("different seeds produced identical payloads")
+	}
+
+	// Chaos and random error decisions replay too
+	chaos := map[string]string{"X-Echo-Error": "random"}
+	want := seededRequest(t, "7", chaos).Code
+	for i := 0; i < 5; i++ {
+		if got := seededRequest(t, "7", chaos).Code; got != want {
+			t.Fatalf("seeded random error changed: %d vs %d", got, want)
+		}
+	}
+}
+
+func TestReportedSeedReplaysUnseededRequest(t *testing.T) {
+	setupTest()
+	payload := map[string]string{"X-Echo-Response-Size": "64"}
+	original := seededRequest(t, "", payload)
+	seed := original.Header().Get("X-Echo-Seed")
+	if _, err := strconv.ParseInt(seed, 10, 64); err != nil {
+		t.Fatalf("unseeded request should report its derived seed, got %q", seed)
+	}
+	replay := seededRequest(t, seed, payload)
+	if !bytes.Equal(original.Body.Bytes(), replay.Body.Bytes()) {
+		t.Error("replaying the reported seed did not reproduce the payload")
+	}
+}
+
+func TestGlobalSeedIsDeterministic(t *testing.T) {
+	setupTest()
+	var seeds [2]string
+	for i := range seeds {
+		withTestRNGSeed(42, func() {
+			seeds[i] = seededRequest(t, "", nil).Header().Get("X-Echo-Seed")
+		})
+	}
+	if seeds[0] != seeds[1] {
+		t.Errorf("global seed should derive the same request seed: %v", seeds)
+	}
+}
+
+func TestLockedRandConcurrentUse(t *testing.T) {
+	rnd := newLockedRand(1)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 16)
+			for j := 0; j < 1000; j++ {
+				rnd.Intn(100)
+				rnd.NormFloat64()
+				rnd.Read(buf)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Raw TCP and UDP traffic has no headers, so its controls come from route rules
+// matching the path "tcp" or "udp" and from environment variables. The delay
+// controls are the same as for HTTP; drops and early closes have their own:
+//
+//	ECHO_RAW_DROP         percentage of messages (TCP) or datagrams (UDP) left unanswered
+//	ECHO_RAW_CLOSE_AFTER  close a TCP connection once this many bytes were echoed;
+//	                      UDP replies are cut to this size instead
+//
+// ECHO_TCP_IDLE_TIMEOUT closes TCP connections that send nothing for that long,
+// and ECHO_UDP_WORKERS bounds how many datagrams are answered at once.
+
+// rawRequest builds the HTTP view of a TCP connection or UDP datagram so rules,
+// seeds and delays apply as they do for echo requests.
+func rawRequest(protocol string, remote net.Addr) *http.Request {
+	r := &http.Request{
+		Method:     strings.ToUpper(protocol),
+		URL:        &url.URL{Path: protocol},
+		RequestURI: protocol,
+		Header:     http.Header{"X-Request-Id": {generateRequestID()}},
+		RemoteAddr: remote.String(),
+	}
+	rec := &headerRecorder{header: http.Header{}}
+	r = withRequestRand(rec, r.WithContext(context.Background()))
+	r = withRouteRule(rec, r)
+
+	counterMutex.Lock()
+	requestCounter++
+	counterMutex.Unlock()
+	return r
+}
+
+// rawControls are the fault settings for one connection or datagram.
+type rawControls struct {
+	drop       int
+	closeAfter int64
+}
+
+func rawControlsFor(r *http.Request) rawControls {
+	drop, _ := strconv.Atoi(getHeaderOrEnv(r, "X-Echo-Raw-Drop", "ECHO_RAW_DROP"))
+	return rawControls{
+		drop:       drop,
+		closeAfter: parseByteSize(getHeaderOrEnv(r, "X-Echo-Raw-Close-After", "ECHO_RAW_CLOSE_AFTER")),
+	}
+}
+
+// startTCPServer serves the raw TCP echo listener.
+func startTCPServer(port string) error {
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+	configLock.RLock()
+	mode := config.TCPMode
+	idle := parseDurationValue(config.TCPIdleTimeout)
+	configLock.RUnlock()
+	log.Printf("Starting raw TCP echo on port %s (%s mode)", port, mode)
+	return serveTCP(listener, mode, idle)
+}
+
+// serveTCP echoes every connection accepted on listener. In "line" mode each
+// newline-terminated line is a message; otherwise each read is. Connections idle
+// for longer than idle are closed; zero keeps them open.
+func serveTCP(listener net.Listener, mode string, idle time.Duration) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go handleTCPConn(conn, mode == "line", idle)
+	}
+}
+
+func handleTCPConn(conn net.Conn, lines bool, idle time.Duration) {
+	defer conn.Close()
+	rawConnections.WithLabelValues("tcp").Inc()
+	r := rawRequest("tcp", conn.RemoteAddr())
+	controls := rawControlsFor(r)
+	rnd := requestRand(r)
+
+	reader := bufio.NewReader(conn)
+	buf := make([]byte, 32*1024)
+	var echoed int64
+	for {
+		var msg []byte
+		var err error
+		if idle > 0 {
+			conn.SetReadDeadline(time.Now().Add(idle))
+		}
+		if lines {
+			msg, err = reader.ReadBytes('\n')
+		} else {
+			var n int
+			n, err = reader.Read(buf)
+			msg = buf[:n]
+		}
+		if len(msg) > 0 {
+			rawBytes.WithLabelValues("tcp", "in").Add(float64(len(msg)))
+			applyDelays(r)
+			if rollPercent(rnd, controls.drop) {
+				log.Printf("Raw TCP: dropping %d bytes from %s", len(msg), r.RemoteAddr)
+				chaosErrors.WithLabelValues("tcp_drop").Inc()
+				msg = nil
+			}
+			closing := controls.closeAfter > 0 && echoed+int64(len(msg)) >= controls.closeAfter
+			if closing {
+				msg = msg[:controls.closeAfter-echoed]
+			}
+			n, writeErr := conn.Write(msg)
+			echoed += int64(n)
+			rawBytes.WithLabelValues("tcp", "out").Add(float64(n))
+			if closing {
+				log.Printf("Raw TCP: closing %s after %d bytes", r.RemoteAddr, echoed)
+				chaosErrors.WithLabelValues("tcp_close").Inc()
+				return
+			}
+			if writeErr != nil {
+				return
+			}
+		}
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				log.Printf("Raw TCP: closing %s after %v idle", r.RemoteAddr, idle)
+			} else if err != io.EOF {
+				log.Printf("Raw TCP read error from %s: %v", r.RemoteAddr, err)
+			}
+			return
+		}
+	}
+}
+
+// startUDPServer serves the raw UDP echo listener.
+func startUDPServer(port string) error {
+	conn, err := net.ListenPacket("udp", ":"+port)
+	if err != nil {
+		return err
+	}
+	configLock.RLock()
+	workers := config.UDPWorkers
+	configLock.RUnlock()
+	log.Printf("Starting raw UDP echo on port %s (%d workers)", port, workers)
+	return serveUDP(conn, workers)
+}
+
+// serveUDP answers each datagram with its own payload. Up to workers datagrams
+// are handled concurrently so a delayed reply does not hold up the others; when
+// all are busy, new datagrams wait in the socket buffer, where the kernel drops
+// them once it is full, as it would for any overloaded UDP server. It returns
+// once conn is closed and the datagrams in flight have been handled.
+func serveUDP(conn net.PacketConn, workers int) error {
+	slots := make(chan struct{}, max(workers, 1))
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			for range cap(slots) {
+				slots <- struct{}{}
+			}
+			return err
+		}
+		msg := append([]byte(nil), buf[:n]...)
+		slots <- struct{}{}
+		go func() {
+			defer func() { <-slots }()
+			handleUDPDatagram(conn, addr, msg)
+		}()
+	}
+}
+
+func handleUDPDatagram(conn net.PacketConn, addr net.Addr, msg []byte) {
+	rawConnections.WithLabelValues("udp").Inc()
+	rawBytes.WithLabelValues("udp", "in").Add(float64(len(msg)))
+	r := rawRequest("udp", addr)
+	controls := rawControlsFor(r)
+
+	applyDelays(r)
+	if rollPercent(requestRand(r), controls.drop) {
+		log.Printf("Raw UDP: dropping %d bytes from %s", len(msg), addr)
+		chaosErrors.WithLabelValues("udp_drop").Inc()
+		return
+	}
+	if controls.closeAfter > 0 && int64(len(msg)) > controls.closeAfter {
+		msg = msg[:controls.closeAfter]
+		chaosErrors.WithLabelValues("udp_truncate").Inc()
+	}
+	n, err := conn.WriteTo(msg, addr)
+	if err != nil {
+		log.Printf("Raw UDP write error to %s: %v", addr, err)
+	}
+	rawBytes.WithLabelValues("udp", "out").Add(float64(n))
+}
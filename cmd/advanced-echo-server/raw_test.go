@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func startTestTCP(t *testing.T, mode string, idle time.Duration) net.Conn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go serveTCP(listener, mode, idle)
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+// startTestUDP serves UDP echo on a local port and returns a connected client.
+// The server is shut down and its workers waited for when the test ends.
+func startTestUDP(t *testing.T, workers int) net.Conn {
+	t.Helper()
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		serveUDP(server, workers)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		server.Close()
+		<-done
+	})
+	conn, err := net.Dial("udp", server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func TestRawTCPEcho(t *testing.T) {
+	setupTest()
+	conn := startTestTCP(t, "bytes", 0)
+	conn.Write([]byte("hello"))
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "hello" {
+		t.Fatalf("expected echo, got %q: %v", buf, err)
+	}
+	conn.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	if got := testutil.ToFloat64(rawConnections.WithLabelValues("tcp")); got != 1 {
+		t.Errorf("expected 1 connection, got %v", got)
+	}
+	if in, out := testutil.ToFloat64(rawBytes.WithLabelValues("tcp", "in")), testutil.ToFloat64(rawBytes.WithLabelValues("tcp", "out")); in != 5 || out != 5 {
+		t.Errorf("expected 5 bytes each way, got %v in and %v out", in, out)
+	}
+}
+
+func TestRawTCPLineModeWithRules(t *testing.T) {
+	setupTest()
+	rulesMutex.Lock()
+	routeRules = []Rule{{Path: "tcp", Latency: "fixed:50ms", CloseAfter: "10"}}
+	rulesMutex.Unlock()
+
+	conn := startTestTCP(t, "line", 0)
+	reader := bufio.NewReader(conn)
+	start := time.Now()
+	conn.Write([]byte("first\n"))
+	if line, err := reader.ReadString('\n'); err != nil || line != "first\n" {
+		t.Fatalf("expected the first line, got %q: %v", line, err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("rule latency was not applied")
+	}
+
+	// Only 4 more bytes fit before the connection is closed
+	conn.Write([]byte("second\n"))
+	rest, err := io.ReadAll(reader)
+	if err != nil || string(rest) != "seco" {
+		t.Errorf("expected the echo to stop after 10 bytes, got %q: %v", rest, err)
+	}
+	if got := testutil.ToFloat64(chaosErrors.WithLabelValues("tcp_close")); got != 1 {
+		t.Errorf("expected a tcp_close chaos count, got %v", got)
+	}
+}
+
+func TestRawUDPEcho(t *testing.T) {
+	setupTest()
+	conn := startTestUDP(t, 4)
+
+	t.Setenv("ECHO_RAW_CLOSE_AFTER", "4")
+	conn.Write([]byte("datagram"))
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "data" {
+		t.Fatalf("expected a truncated echo, got %q: %v", buf[:n], err)
+	}
+
+	t.Setenv("ECHO_RAW_DROP", "100")
+	conn.Write([]byte("lost"))
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if n, err := conn.Read(buf); err == nil {
+		t.Errorf("dropped datagram was answered with %q", buf[:n])
+	}
+	if got := testutil.ToFloat64(rawConnections.WithLabelValues("udp")); got != 2 {
+		t.Errorf("expected 2 datagrams counted, got %v", got)
+	}
+	if got := testutil.ToFloat64(chaosErrors.WithLabelValues("udp_drop")); got != 1 {
+		t.Errorf("expected a udp_drop chaos count, got %v", got)
+	}
+}
+
+func TestRawTCPIdleTimeout(t *testing.T) {
+	setupTest()
+	conn := startTestTCP(t, "bytes", 100*time.Millisecond)
+	conn.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("expected echo before going idle: %v", err)
+	}
+	start := time.Now()
+	if _, err := conn.Read(buf); err != io.EOF {
+		t.Fatalf("idle connection should be closed by the server, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("idle connection closed only after %v", elapsed)
+	}
+}
+
+func TestRawUDPWorkerLimit(t *testing.T) {
+	setupTest()
+	conn := startTestUDP(t, 1)
+
+	// With one worker, delayed datagrams are answered one after another
+	t.Setenv("ECHO_DELAY", "100")
+	start := time.Now()
+	conn.Write([]byte("a"))
+	conn.Write([]byte("b"))
+	buf := make([]byte, 8)
+	for i := 0; i < 2; i++ {
+		if _, err := conn.Read(buf); err != nil {
+			t.Fatalf("reply %d: %v", i+1, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("two datagrams with one worker took only %v", elapsed)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// maxRedirectHops bounds the length of a generated redirect chain.
+const maxRedirectHops = 1000
+
+// redirectFinalKey marks the last hop of a chain so processRedirect does not restart it.
+type redirectFinalKey struct{}
+
+// redirectCodes are the status codes a redirect hop may use.
+var redirectCodes = map[int]bool{301: true, 302: true, 303: true, 307: true, 308: true}
+
+// redirectHandler serves /redirect/{n}: n hops, then the echo response (or a
+// redirect to target). Query parameters select the hop behavior:
+//
+//	code=301|302|303|307|308   status for every hop (default 302)
+//	type=relative|absolute|path  Location form: /redirect/2, http://host/redirect/2 or 2
+//	scheme=http|https          absolute Location with this scheme (cross-scheme hops)
+//	loop=true                  redirect forever between two URLs
+//	target=<url>               final Location after the last hop
+func redirectHandler(w http.ResponseWriter, r *http.Request) {
+	n, err := strconv.Atoi(mux.Vars(r)["n"])
+	if err != nil || n < 0 || n > maxRedirectHops {
+		http.Error(w, fmt.Sprintf("Redirect count must be between 0 and %d", maxRedirectHops), http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	if n == 0 && query.Get("target") == "" && query.Get("loop") != "true" {
+		// Final hop: echo what actually arrived so clients can verify method and body handling
+		w.Header().Set("X-Echo-Redirect-Method", r.Method)
+		echoHandler(w, r.WithContext(context.WithValue(r.Context(), redirectFinalKey{}, true)))
+		return
+	}
+	writeRedirectHop(w, r, n, query)
+}
+
+// processRedirect starts a redirect chain for X-Echo-Redirect / ECHO_REDIRECT,
+// e.g. "3" or "3,code=307,type=absolute". It returns true when a hop was written.
+func processRedirect(w http.ResponseWriter, r *http.Request) bool {
+	if final, _ := r.Context().Value(redirectFinalKey{}).(bool); final {
+		return false
+	}
+	spec := getHeaderOrEnv(r, "X-Echo-Redirect", "ECHO_REDIRECT")
+	if spec == "" {
+		return false
+	}
+	parts := strings.Split(spec, ",")
+	n, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || n <= 0 || n > maxRedirectHops {
+		return false
+	}
+	query := url.Values{}
+	for _, part := range parts[1:] {
+		if key, value, ok := strings.Cut(part, "="); ok {
+			query.Set(strings.TrimSpace(key), strings.TrimSpace(value))
+		}
+	}
+	writeRedirectHop(w, r, n, query)
+	return true
+}
+
+// writeRedirectHop answers with one hop towards /redirect/{n-1}, the target or the loop partner.
+func writeRedirectHop(w http.ResponseWriter, r *http.Request, n int, query url.Values) {
+	code := http.StatusFound
+	if c := query.Get("code"); c != "" {
+		code, _ = strconv.Atoi(c)
+		if !redirectCodes[code] {
+			http.Error(w, "Redirect code must be one of 301, 302, 303, 307, 308", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Drain the body so the connection can be reused for the next hop
+	configLock.RLock()
+	maxBodySize := config.MaxBodySize
+	configLock.RUnlock()
+	if r.Body != nil {
+		io.Copy(io.Discard, io.LimitReader(r.Body, maxBodySize))
+	}
+
+	next := n - 1
+	if query.Get("loop") == "true" {
+		next = n%2 + 1
+	}
+	location := ""
+	if target := query.Get("target"); target != "" && next <= 0 && query.Get("loop") != "true" {
+		location = target
+	} else {
+		location = redirectLocation(r, next, query)
+	}
+
+	log.Printf("Redirect: %d to %s (%d hops left)", code, location, max(next, 0))
+	w.Header().Set("Location", location)
+	w.Header().Set("X-Echo-Redirect-Hop", strconv.Itoa(n))
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(code)
+	if r.Method != "HEAD" {
+		fmt.Fprintf(w, "Redirecting to %s\n", location)
+	}
+}
+
+// redirectLocation builds the Location for /redirect/{n} in the requested form.
+func redirectLocation(r *http.Request, n int, query url.Values) string {
+	path := "/redirect/" + strconv.Itoa(n)
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	scheme := query.Get("scheme")
+	switch {
+	case scheme != "" || query.Get("type") == "absolute":
+		if scheme == "" {
+			scheme = "http"
+			if r.TLS != nil {
+				scheme = "https"
+			}
+		}
+		return scheme + "://" + r.Host + path
+	case query.Get("type") == "path" && strings.HasPrefix(r.URL.Path, "/redirect/"):
+		// Relative to the current /redirect/ segment, resolved by the client
+		return strings.TrimPrefix(path, "/redirect/")
+	default:
+		return path
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedirectChainPreservesMethodAndBody(t *testing.T) {
+	setupTest()
+	server := httptest.NewServer(setupRoutes())
+	defer server.Close()
+
+	cases := []struct {
+		code       string
+		wantMethod string
+		wantBody   string
+	}{
+		{"307", "POST", "payload"},
+		{"308", "POST", "payload"},
+		{"303", "GET", ""},
+	}
+	for _, tc := range cases {
+		var hops int
+		client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			hops++
+			return nil
+		}}
+		resp, err := client.Post(server.URL+"/redirect/3?code="+tc.code, "text/plain", strings.NewReader("payload"))
+		if err != nil {
+			t.Fatalf("code %s: %v", tc.code, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if hops != 3 || resp.StatusCode != http.StatusOK {
+			t.Errorf("code %s: %d hops, status %d", tc.code, hops, resp.StatusCode)
+		}
+		if got := resp.Header.Get("X-Echo-Redirect-Method"); got != tc.wantMethod {
+			t.Errorf("code %s: final method %s, want %s", tc.code, got, tc.wantMethod)
+		}
+		if tc.wantBody != "" && string(body) != tc.wantBody {
+			t.Errorf("code %s: final body %q, want %q", tc.code, body, tc.wantBody)
+		}
+	}
+}
+
+func TestRedirectLocationForms(t *testing.T) {
+	setupTest()
+	router := setupRoutes()
+	hop := func(target string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", target, nil)
+		req.Host = "echo.test"
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	cases := map[string]string{
+		"/redirect/2":                     "/redirect/1",
+		"/redirect/2?type=absolute":       "http://echo.test/redirect/1?type=absolute",
+		"/redirect/2?type=path":           "1?type=path",
+		"/redirect/2?scheme=https":        "https://echo.test/redirect/1?scheme=https",
+		"/redirect/1?target=%2Fdone":      "/done",
+		"/redirect/1?loop=true":           "/redirect/2?loop=true",
+		"/redirect/2?loop=true":           "/redirect/1?loop=true",
+		"/redirect/5?code=301&type=bogus": "/redirect/4?code=301&type=bogus",
+	}
+	for target, want := range cases {
+		rr := hop(target, nil)
+		if got := rr.Header().Get("Location"); got != want {
+			t.Errorf("%s: Location %q, want %q", target, got, want)
+		}
+	}
+	if rr := hop("/redirect/1?code=304", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid code should be rejected, got %d", rr.Code)
+	}
+
+	rr := hop("/orders", map[string]string{"X-Echo-Redirect": "2,code=308"})
+	if rr.Code != http.StatusPermanentRedirect || rr.Header().Get("Location") != "/redirect/1?code=308" {
+		t.Errorf("header redirect: %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	// The header is resent on each hop but must not restart the chain at the end
+	rr = hop("/redirect/0", map[string]string{"X-Echo-Redirect": "2"})
+	if rr.Code != http.StatusOK {
+		t.Errorf("final hop should echo, got %d", rr.Code)
+	}
+}
+
+func TestRedirectEnvChainEnds(t *testing.T) {
+	setupTest()
+	t.Setenv("ECHO_REDIRECT", "3")
+	server := httptest.NewServer(setupRoutes())
+	defer server.Close()
+
+	var hops int
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		hops++
+		return nil
+	}}
+	resp, err := client.Get(server.URL + "/orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || hops != 3 {
+		t.Errorf("expected a 200 after 3 hops, got %d after %d", resp.StatusCode, hops)
+	}
+}
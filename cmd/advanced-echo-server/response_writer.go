@@ -13,10 +13,17 @@ type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
 	written    bool
+	capture    bool         // whether the body is captured for logging
+	captureMax int64        // capture limit in bytes; 0 or less captures everything
 	bodyBuf    bytes.Buffer // captures response body up to MaxLogBodySize in middleware
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
+	// Informational responses precede the final status and may repeat
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		rw.ResponseWriter.WriteHeader(code)
+		return
+	}
 	if !rw.written {
 		rw.statusCode = code
 		rw.ResponseWriter.WriteHeader(code)
@@ -27,12 +34,23 @@ func (rw *responseWriter) WriteHeader(code int) {
 func (rw *responseWriter) Write(p []byte) (int, error) {
 	// Always write to the underlying writer
 	n, err := rw.ResponseWriter.Write(p)
-	// Capture up to configured limit; limit is enforced in middleware where it's read
-	// Here we just buffer everything; middleware will truncate on read
-	rw.bodyBuf.Write(p[:n])
+	// Capture only when the body will be logged, and never past the log limit, so
+	// large and long-lived streams pass through in constant memory
+	if rw.capture {
+		captured := p[:n]
+		if rw.captureMax > 0 {
+			captured = captured[:min(int64(len(captured)), max(rw.captureMax-int64(rw.bodyBuf.Len()), 0))]
+		}
+		rw.bodyBuf.Write(captured)
+	}
 	return n, err
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func (rw *responseWriter) Flush() {
 	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
 		flusher.Flush()
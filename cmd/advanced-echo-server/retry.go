@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultFailTTL is how long an idle retry key is remembered.
+const defaultFailTTL = 5 * time.Minute
+
+type retryAttempt struct {
+	count   int
+	expires time.Time
+}
+
+var (
+	retryAttempts  = map[string]*retryAttempt{}
+	retryMutex     sync.Mutex
+	retryLastSweep time.Time
+)
+
+// retryKey identifies the logical request being retried: the header named by
+// X-Echo-Fail-Key when the request carries it, else Idempotency-Key, else an
+// X-Request-ID the client sent. Without any of these, retries are recognised by
+// method, path and client host.
+func retryKey(r *http.Request) string {
+	if name := getHeaderOrEnv(r, "X-Echo-Fail-Key", "ECHO_FAIL_KEY"); name != "" {
+		if v := r.Header.Get(name); v != "" {
+			return name + ":" + v
+		}
+	}
+	if v := r.Header.Get("Idempotency-Key"); v != "" {
+		return "Idempotency-Key:" + v
+	}
+	if v := clientRequestID(r); v != "" {
+		return "X-Request-ID:" + v
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "request:" + r.Method + " " + r.URL.Path + " " + host
+}
+
+// nextAttempt increments and returns the attempt number for key, dropping
+// keys that have been idle longer than their TTL.
+func nextAttempt(key string, ttl time.Duration) int {
+	now := time.Now()
+	retryMutex.Lock()
+	defer retryMutex.Unlock()
+	if now.Sub(retryLastSweep) > 10*time.Second {
+		for k, a := range retryAttempts {
+			if now.After(a.expires) {
+				delete(retryAttempts, k)
+			}
+		}
+		retryLastSweep = now
+	}
+	a, ok := retryAttempts[key]
+	if !ok || now.After(a.expires) {
+		a = &retryAttempt{}
+		retryAttempts[key] = a
+	}
+	a.count++
+	a.expires = now.Add(ttl)
+	return a.count
+}
+
+// processFailTimes fails the first N attempts of each logical request, then lets
+// it through. It returns true when a failure response was written.
+func processFailTimes(w http.ResponseWriter, r *http.Request) bool {
+	times, err := strconv.Atoi(getHeaderOrEnv(r, "X-Echo-Fail-Times", "ECHO_FAIL_TIMES"))
+	if err != nil || times <= 0 {
+		return false
+	}
+	status := http.StatusServiceUnavailable
+	if s, err := strconv.Atoi(getHeaderOrEnv(r, "X-Echo-Fail-Status", "ECHO_FAIL_STATUS")); err == nil && s >= 100 && s <= 599 {
+		status = s
+	}
+	ttl := parseDurationValue(getHeaderOrEnv(r, "X-Echo-Fail-TTL", "ECHO_FAIL_TTL"))
+	if ttl <= 0 {
+		ttl = defaultFailTTL
+	}
+
+	attempt := nextAttempt(retryKey(r), ttl)
+	w.Header().Set("X-Echo-Attempt", strconv.Itoa(attempt))
+	if attempt > times {
+		return false
+	}
+
+	log.Printf("Fail-times: failing attempt %d of %d with %d for %s", attempt, times, status, r.RemoteAddr)
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(status)
+	w.Write([]byte(fmt.Sprintf("Simulated failure on attempt %d of %d", attempt, times)))
+	chaosErrors.WithLabelValues("fail_times").Inc()
+	return true
+}
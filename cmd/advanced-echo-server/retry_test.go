@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func failTimesRequest(headers map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/pay", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestFailTimesThenSucceed(t *testing.T) {
+	setupTest()
+	headers := map[string]string{"X-Echo-Fail-Times": "2", "X-Echo-Fail-Status": "502", "Idempotency-Key": "order-1"}
+	for attempt, want := range []int{502, 502, 200, 200} {
+		rr := failTimesRequest(headers)
+		if rr.Code != want {
+			t.Errorf("attempt %d: got %d, want %d", attempt+1, rr.Code, want)
+		}
+		if got := rr.Header().Get("X-Echo-Attempt"); got != strconv.Itoa(attempt+1) {
+			t.Errorf("attempt %d: X-Echo-Attempt = %q", attempt+1, got)
+		}
+	}
+
+	// A different key gets its own story
+	headers["Idempotency-Key"] = "order-2"
+	if rr := failTimesRequest(headers); rr.Code != 502 {
+		t.Errorf("new key should start failing again, got %d", rr.Code)
+	}
+}
+
+func TestFailTimesCustomKeyAndTTL(t *testing.T) {
+	setupTest()
+	headers := map[string]string{
+		"X-Echo-Fail-Times": "1",
+		"X-Echo-Fail-Key":   "X-Client-Op",
+		"X-Echo-Fail-TTL":   "50ms",
+		"X-Client-Op":       "op-9",
+		"Idempotency-Key":   "ignored",
+	}
+	if rr := failTimesRequest(headers); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("first attempt should fail with default 503, got %d", rr.Code)
+	}
+	if rr := failTimesRequest(headers); rr.Code != http.StatusOK {
+		t.Fatalf("second attempt should succeed, got %d", rr.Code)
+	}
+	time.Sleep(80 * time.Millisecond)
+	if rr := failTimesRequest(headers); rr.Code != http.StatusServiceUnavailable || rr.Header().Get("X-Echo-Attempt") != "1" {
+		t.Errorf("expired key should restart at attempt 1, got %d attempt %s", rr.Code, rr.Header().Get("X-Echo-Attempt"))
+	}
+}
+
+func TestFailTimesMissingCustomKeyFallsBack(t *testing.T) {
+	setupTest()
+	headers := map[string]string{
+		"X-Echo-Fail-Times": "1",
+		"X-Echo-Fail-Key":   "X-Client-Op",
+		"Idempotency-Key":   "order-7",
+	}
+	if rr := failTimesRequest(headers); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("first attempt should fail, got %d", rr.Code)
+	}
+	// Without X-Client-Op the attempts are still counted, here by Idempotency-Key
+	if rr := failTimesRequest(headers); rr.Code != http.StatusOK || rr.Header().Get("X-Echo-Attempt") != "2" {
+		t.Errorf("retry should count as attempt 2, got %d attempt %s", rr.Code, rr.Header().Get("X-Echo-Attempt"))
+	}
+
+	// With no key at all it falls back to method, path and client
+	delete(headers, "Idempotency-Key")
+	failTimesRequest(headers)
+	if rr := failTimesRequest(headers); rr.Code != http.StatusOK || rr.Header().Get("X-Echo-Attempt") != "2" {
+		t.Errorf("keyless retry should count as attempt 2, got %d attempt %s", rr.Code, rr.Header().Get("X-Echo-Attempt"))
+	}
+}
+
+func TestFailTimesConcurrentKeys(t *testing.T) {
+	setupTest()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			headers := map[string]string{"X-Echo-Fail-Times": "3", "X-Request-ID": "req-" + strconv.Itoa(id)}
+			for attempt := 1; attempt <= 4; attempt++ {
+				rr := failTimesRequest(headers)
+				if want := attempt > 3; (rr.Code == http.StatusOK) != want {
+					t.Errorf("key %d attempt %d: got %d", id, attempt, rr.Code)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestFailTimesIgnoresGeneratedRequestIDs(t *testing.T) {
+	setupTest()
+	router := setupRoutes()
+	send := func(path, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Echo-Fail-Times", "1")
+		req.RemoteAddr = remote
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := send("/pay", "10.0.0.1:1000"); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("first attempt should fail, got %d", rr.Code)
+	}
+	// A fresh server-generated X-Request-ID must not make every retry a first attempt
+	if rr := send("/pay", "10.0.0.1:2000"); rr.Code != http.StatusOK || rr.Header().Get("X-Echo-Attempt") != "2" {
+		t.Errorf("retry without a key should count as attempt 2, got %d attempt %s", rr.Code, rr.Header().Get("X-Echo-Attempt"))
+	}
+	if rr := send("/pay", "10.0.0.2:1000"); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("another client should get its own sequence, got %d", rr.Code)
+	}
+	if rr := send("/refund", "10.0.0.1:1000"); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("another path should get its own sequence, got %d", rr.Code)
+	}
+}
@@ -1,10 +1,23 @@
 package main
 
 import (
+	"slices"
+
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// adminPaths are the server's own management and observability endpoints, which
+// middleware that acts on echo traffic leaves alone.
+var adminPaths = []string{
+	"/health", "/ready", "/info", "/history", "/replay", "/scenario",
+	"/rules", "/schedules", "/proxy/faults", "/slow-read", "/metrics", "/web-sse",
+}
+
+func isAdminPath(path string) bool {
+	return slices.Contains(adminPaths, path)
+}
+
 // setupRoutes configures all HTTP routes and middleware for the server.
 func setupRoutes() *mux.Router {
 	router := mux.NewRouter()
@@ -16,6 +29,16 @@ func setupRoutes() *mux.Router {
 	if rateLimiter != nil {
 		router.Use(rateLimitMiddleware)
 	}
+	configLock.RLock()
+	mirrorEnabled := config.MirrorTargets != ""
+	http3Enabled := config.HTTP3Port != ""
+	configLock.RUnlock()
+	if mirrorEnabled {
+		router.Use(mirrorMiddleware)
+	}
+	if http3Enabled {
+		router.Use(altSvcMiddleware)
+	}
 
 	// Health check endpoints
 	router.HandleFunc("/health", healthHandler).Methods("GET")
@@ -28,9 +51,21 @@ func setupRoutes() *mux.Router {
 	// router.HandleFunc("/ws", websocketHandler) // WebSocket route (if needed)
 	router.HandleFunc("/sse", sseHandler)
 
+	// Full-duplex streaming echo
+	router.HandleFunc("/duplex", duplexHandler).Methods("POST", "PUT")
+
+	// GraphQL echo and mock endpoint
+	router.HandleFunc("/graphql", graphqlHandler).Methods("GET", "POST")
+
+	// JSON-RPC 2.0 over HTTP and WebSocket
+	router.HandleFunc("/jsonrpc", jsonrpcHandler).Methods("GET", "POST")
+
 	// Embedded frontend for SSE
 	router.HandleFunc("/web-sse", serveFrontendSSE)
 
+	// Redirect chains
+	router.HandleFunc("/redirect/{n}", redirectHandler)
+
 	// Request history and replay
 	router.HandleFunc("/history", historyHandler).Methods("GET")
 	router.HandleFunc("/replay", replayHandler).Methods("POST")
@@ -38,9 +73,24 @@ func setupRoutes() *mux.Router {
 	// Scenario management
 	router.HandleFunc("/scenario", scenarioHandler).Methods("GET", "POST")
 
+	// Route rule management
+	router.HandleFunc("/rules", rulesHandler).Methods("GET", "POST")
+
+	// Outage schedule management
+	router.HandleFunc("/schedules", schedulesHandler).Methods("GET", "POST")
+
+	// Proxy fault management
+	router.HandleFunc("/proxy/faults", proxyFaultsHandler).Methods("GET", "POST")
+
+	// Slow read route management
+	router.HandleFunc("/slow-read", slowReadHandler).Methods("GET", "POST")
+
 	// Prometheus metrics
 	router.Handle("/metrics", promhttp.Handler())
 
+	// gRPC-Web and Connect calls, recognized by content type
+	router.MatcherFunc(isRPCWebRequest).HandlerFunc(rpcWebHandler)
+
 	// Everything else is pure echo with testing features
 	router.PathPrefix("/").HandlerFunc(echoHandler)
 
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Rule applies testing behaviors to matching echo requests. Rules take precedence
+// over the equivalent environment variables but never over per-request headers,
+// and scenario responses only see requests that no rule has already answered.
+type Rule struct {
+	Name       string            `yaml:"name,omitempty" json:"name,omitempty"`
+	Path       string            `yaml:"path" json:"path"`
+	Methods    []string          `yaml:"methods,omitempty" json:"methods,omitempty"`
+	Headers    map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Chaos      int               `yaml:"chaos,omitempty" json:"chaos,omitempty"`
+	Errors     string            `yaml:"errors,omitempty" json:"errors,omitempty"`
+	Latency    string            `yaml:"latency,omitempty" json:"latency,omitempty"`
+	Status     int               `yaml:"status,omitempty" json:"status,omitempty"`
+	Throttle   string            `yaml:"throttle,omitempty" json:"throttle,omitempty"`
+	Drop       int               `yaml:"drop,omitempty" json:"drop,omitempty"`
+	CloseAfter string            `yaml:"close_after,omitempty" json:"close_after,omitempty"`
+}
+
+var (
+	routeRules []Rule
+	rulesMutex sync.RWMutex
+)
+
+type routeRuleKey struct{}
+
+// loadRules reads route rules from a YAML file.
+func loadRules(path string) {
+	if rules, ok := loadConfigList[Rule](path, "rules"); ok {
+		setRules(rules)
+	}
+}
+
+// setRules installs the route rules.
+func setRules(rules []Rule) error {
+	rulesMutex.Lock()
+	routeRules = rules
+	rulesMutex.Unlock()
+	return nil
+}
+
+// rulesHandler lists (GET) or replaces (POST) the route rules at runtime.
+func rulesHandler(w http.ResponseWriter, r *http.Request) {
+	configListHandler(w, r, "rules", func() []Rule {
+		rulesMutex.RLock()
+		defer rulesMutex.RUnlock()
+		return routeRules
+	}, setRules)
+}
+
+// matches reports whether the rule applies to r. Header matchers use the same
+// patterns as paths; an empty pattern only requires the header to be present.
+func (rule Rule) matches(r *http.Request) bool {
+	if !matchRoute(rule.Path, rule.Methods, r) {
+		return false
+	}
+	for name, pattern := range rule.Headers {
+		value := r.Header.Get(name)
+		if value == "" || !matchPath(pattern, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// withRouteRule attaches the first matching rule to the request context.
+func withRouteRule(w http.ResponseWriter, r *http.Request) *http.Request {
+	rulesMutex.RLock()
+	defer rulesMutex.RUnlock()
+	for i := range routeRules {
+		if routeRules[i].matches(r) {
+			rule := routeRules[i]
+			if rule.Name != "" {
+				w.Header().Set("X-Echo-Rule", rule.Name)
+			}
+			return r.WithContext(context.WithValue(r.Context(), routeRuleKey{}, rule))
+		}
+	}
+	return r
+}
+
+// ruleControl returns the value the request's rule supplies for a control header.
+func ruleControl(r *http.Request, header string) string {
+	rule, ok := r.Context().Value(routeRuleKey{}).(Rule)
+	if !ok {
+		return ""
+	}
+	switch http.CanonicalHeaderKey(header) {
+	case "X-Echo-Chaos":
+		if rule.Chaos > 0 {
+			return strconv.Itoa(rule.Chaos)
+		}
+	case "X-Echo-Chaos-Errors":
+		return rule.Errors
+	case "X-Echo-Latency-Dist":
+		return rule.Latency
+	case "X-Echo-Status":
+		if rule.Status > 0 {
+			return strconv.Itoa(rule.Status)
+		}
+	case "X-Echo-Throttle":
+		return rule.Throttle
+	case "X-Echo-Raw-Drop":
+		if rule.Drop > 0 {
+			return strconv.Itoa(rule.Drop)
+		}
+	case "X-Echo-Raw-Close-After":
+		return rule.CloseAfter
+	}
+	return ""
+}
+
+// pickChaosStatus chooses a chaos error status from a mix such as "503" or
+// "503:3,500:1" (status:weight), falling back to the default set.
+func pickChaosStatus(mix string, rnd *lockedRand) int {
+	var statuses, weights []int
+	total := 0
+	for _, part := range splitList(mix) {
+		code, weight, found := strings.Cut(part, ":")
+		status, err := strconv.Atoi(strings.TrimSpace(code))
+		if err != nil || status < 100 || status > 599 {
+			continue
+		}
+		w := 1
+		if found {
+			if w, err = strconv.Atoi(strings.TrimSpace(weight)); err != nil || w <= 0 {
+				continue
+			}
+		}
+		statuses = append(statuses, status)
+		weights = append(weights, w)
+		total += w
+	}
+	if total == 0 {
+		errors := []int{500, 502, 503, 504, 408, 429}
+		return errors[rnd.Intn(len(errors))]
+	}
+	n := rnd.Intn(total)
+	for i, w := range weights {
+		if n < w {
+			return statuses[i]
+		}
+		n -= w
+	}
+	return statuses[len(statuses)-1]
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRulesScopeBehaviorToRoutes(t *testing.T) {
+	setupTest()
+	routeRules = []Rule{
+		{Name: "payments-outage", Path: "/payments/*", Chaos: 100, Errors: "503"},
+		{Path: "/search", Methods: []string{"GET"}, Latency: "60ms"},
+		{Path: "/beta", Headers: map[string]string{"X-Tenant": "beta-*"}, Status: 418},
+	}
+	router := setupRoutes()
+
+	serve := func(method, path string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 5; i++ {
+		rr := serve("GET", "/payments/charge", nil)
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected rule chaos 503, got %d", rr.Code)
+		}
+		if rr.Header().Get("X-Echo-Rule") != "payments-outage" {
+			t.Errorf("expected matched rule name, got %q", rr.Header().Get("X-Echo-Rule"))
+		}
+	}
+
+	start := time.Now()
+	if rr := serve("GET", "/search", nil); rr.Code != http.StatusOK {
+		t.Errorf("search should succeed, got %d", rr.Code)
+	}
+	if dur := time.Since(start); dur < 60*time.Millisecond {
+		t.Errorf("rule latency not applied: %v", dur)
+	}
+	start = time.Now()
+	serve("POST", "/search", nil)
+	if dur := time.Since(start); dur >= 60*time.Millisecond {
+		t.Errorf("method filter ignored: %v", dur)
+	}
+
+	if rr := serve("GET", "/beta", map[string]string{"X-Tenant": "beta-eu"}); rr.Code != http.StatusTeapot {
+		t.Errorf("header matcher should apply status, got %d", rr.Code)
+	}
+	if rr := serve("GET", "/beta", map[string]string{"X-Tenant": "prod"}); rr.Code != http.StatusOK {
+		t.Errorf("non-matching header should stay clean, got %d", rr.Code)
+	}
+	if rr := serve("GET", "/other", nil); rr.Code != http.StatusOK || rr.Header().Get("X-Echo-Rule") != "" {
+		t.Errorf("unmatched path should stay clean, got %d", rr.Code)
+	}
+	if rr := serve("GET", "/metrics", nil); rr.Code != http.StatusOK {
+		t.Errorf("metrics must not be affected by rules, got %d", rr.Code)
+	}
+}
+
+func TestRulePrecedence(t *testing.T) {
+	setupTest()
+	routeRules = []Rule{{Path: "/orders", Status: 503}}
+	scenarios.Store("/orders", []Response{{Status: 201, Body: "created"}})
+	scenarioIndex.Store("/orders", 0)
+	os.Setenv("ECHO_STATUS", "500")
+	defer os.Unsetenv("ECHO_STATUS")
+
+	req, _ := http.NewRequest("GET", "/orders", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("rule should override env and scenario, got %d", rr.Code)
+	}
+
+	req.Header.Set("X-Echo-Status", "404")
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("header should override rule, got %d", rr.Code)
+	}
+}
+
+func TestPickChaosStatusMix(t *testing.T) {
+	rnd := newLockedRand(1)
+	counts := map[int]int{}
+	for i := 0; i < 4000; i++ {
+		counts[pickChaosStatus("503:3,500:1", rnd)]++
+	}
+	if len(counts) != 2 || counts[503] < 2700 || counts[503] > 3300 {
+		t.Errorf("unexpected weighted mix: %v", counts)
+	}
+}
+
+func TestRulesHandlerAndFile(t *testing.T) {
+	setupTest()
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	os.WriteFile(path, []byte("- path: /payments/*\n  chaos: 20\n  errors: \"503\"\n"), 0o644)
+	loadRules(path)
+	if len(routeRules) != 1 || routeRules[0].Chaos != 20 || routeRules[0].Errors != "503" {
+		t.Fatalf("rules file not loaded: %+v", routeRules)
+	}
+
+	data, _ := json.Marshal([]Rule{{Path: "/search", Latency: "2s"}})
+	req, _ := http.NewRequest("POST", "/rules", bytes.NewReader(data))
+	rr := httptest.NewRecorder()
+	rulesHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("POST /rules failed: %d", rr.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/rules", nil)
+	rr = httptest.NewRecorder()
+	rulesHandler(rr, req)
+	var got []Rule
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil || len(got) != 1 || got[0].Latency != "2s" {
+		t.Errorf("GET /rules returned %s", rr.Body.String())
+	}
+}
+
+func TestRuleLatencyOutranksEnvDelay(t *testing.T) {
+	setupTest()
+	routeRules = []Rule{{Path: "/search", Latency: "60ms"}}
+	t.Setenv("ECHO_DELAY", "400")
+	t.Setenv("ECHO_LATENCY", "400")
+
+	serve := func(headers map[string]string) time.Duration {
+		req, _ := http.NewRequest("GET", "/search", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		start := time.Now()
+		setupRoutes().ServeHTTP(httptest.NewRecorder(), req)
+		return time.Since(start)
+	}
+
+	if dur := serve(nil); dur < 60*time.Millisecond || dur >= 300*time.Millisecond {
+		t.Errorf("rule latency should replace the env delay, took %v", dur)
+	}
+	if dur := serve(map[string]string{"X-Echo-Delay": "1"}); dur >= 60*time.Millisecond {
+		t.Errorf("a delay header should override the rule, took %v", dur)
+	}
+}
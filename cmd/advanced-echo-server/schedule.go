@@ -0,0 +1,332 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Schedule is a time window during which matching echo requests are failed or slowed.
+// A window is defined by exactly one of:
+//
+//	every + for (+ offset)   recurring, e.g. 30s out of every 5m
+//	start / end              one-shot, as t+60s (relative to load time) or RFC 3339
+//	cron + for               5-field cron; each match opens a window lasting for (default 1m)
+type Schedule struct {
+	Name          string   `yaml:"name" json:"name"`
+	Path          string   `yaml:"path,omitempty" json:"path,omitempty"`
+	Methods       []string `yaml:"methods,omitempty" json:"methods,omitempty"`
+	Every         string   `yaml:"every,omitempty" json:"every,omitempty"`
+	For           string   `yaml:"for,omitempty" json:"for,omitempty"`
+	Offset        string   `yaml:"offset,omitempty" json:"offset,omitempty"`
+	Start         string   `yaml:"start,omitempty" json:"start,omitempty"`
+	End           string   `yaml:"end,omitempty" json:"end,omitempty"`
+	Cron          string   `yaml:"cron,omitempty" json:"cron,omitempty"`
+	Status        int      `yaml:"status,omitempty" json:"status,omitempty"`
+	Rate          int      `yaml:"rate,omitempty" json:"rate,omitempty"`
+	Latency       string   `yaml:"latency,omitempty" json:"latency,omitempty"`
+	LatencyFactor float64  `yaml:"latency_factor,omitempty" json:"latency_factor,omitempty"`
+
+	cron *cronSpec // parsed Cron, set when the schedule is installed
+}
+
+// SchedulePhase reports which windows are open at a point in time.
+type SchedulePhase struct {
+	Phase  string   `json:"phase"`
+	Active []string `json:"active"`
+}
+
+var (
+	schedules     []Schedule
+	scheduleEpoch time.Time
+	scheduleMutex sync.RWMutex
+)
+
+// loadSchedules reads outage schedules from a YAML file.
+func loadSchedules(path string) {
+	list, ok := loadConfigList[Schedule](path, "schedule")
+	if !ok {
+		return
+	}
+	if err := setSchedules(list); err != nil {
+		log.Printf("Invalid schedule file: %v", err)
+	}
+}
+
+// setSchedules validates and installs schedules; relative times count from now.
+func setSchedules(list []Schedule) error {
+	list = slices.Clone(list)
+	for i := range list {
+		if err := list[i].validate(); err != nil {
+			return fmt.Errorf("schedule %d (%s): %w", i, list[i].Name, err)
+		}
+		if list[i].Every == "" && list[i].Cron != "" {
+			spec, _ := parseCron(list[i].Cron)
+			list[i].cron = &spec
+		}
+	}
+	scheduleMutex.Lock()
+	previous := schedules
+	schedules = list
+	scheduleEpoch = time.Now()
+	scheduleMutex.Unlock()
+	// Drop gauges of schedules that were replaced
+	for _, s := range previous {
+		if !slices.ContainsFunc(list, func(n Schedule) bool { return n.Name == s.Name }) {
+			scheduleActive.DeleteLabelValues(s.Name)
+		}
+	}
+	updateScheduleMetrics(time.Now())
+	return nil
+}
+
+// schedulesHandler lists (GET) or replaces (POST) the outage schedules at runtime.
+func schedulesHandler(w http.ResponseWriter, r *http.Request) {
+	configListHandler(w, r, "schedules", func() []Schedule {
+		scheduleMutex.RLock()
+		defer scheduleMutex.RUnlock()
+		return schedules
+	}, setSchedules)
+}
+
+func (s Schedule) validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch {
+	case s.Every != "":
+		if parseDurationValue(s.Every) <= 0 || parseDurationValue(s.For) <= 0 {
+			return fmt.Errorf("every requires positive every and for durations")
+		}
+	case s.Cron != "":
+		if _, err := parseCron(s.Cron); err != nil {
+			return err
+		}
+	case s.Start != "" || s.End != "":
+		if _, ok := parseScheduleTime(s.Start, time.Time{}); !ok && s.Start != "" {
+			return fmt.Errorf("invalid start %q", s.Start)
+		}
+		if _, ok := parseScheduleTime(s.End, time.Time{}); !ok && s.End != "" {
+			return fmt.Errorf("invalid end %q", s.End)
+		}
+	default:
+		return fmt.Errorf("one of every, cron or start/end is required")
+	}
+	if s.LatencyFactor < 0 {
+		return fmt.Errorf("latency_factor must not be negative")
+	}
+	return nil
+}
+
+// parseScheduleTime parses "t+90s" relative to epoch or an RFC 3339 timestamp.
+func parseScheduleTime(spec string, epoch time.Time) (time.Time, bool) {
+	if rel, ok := strings.CutPrefix(strings.TrimSpace(spec), "t+"); ok {
+		d := parseDurationValue(rel)
+		return epoch.Add(d), d > 0 || rel == "0"
+	}
+	t, err := time.Parse(time.RFC3339, spec)
+	return t, err == nil
+}
+
+// active reports whether the schedule's window is open at now.
+func (s Schedule) active(now, epoch time.Time) bool {
+	window := parseDurationValue(s.For)
+	switch {
+	case s.Every != "":
+		elapsed := now.Sub(epoch) - parseDurationValue(s.Offset)
+		return elapsed >= 0 && elapsed%parseDurationValue(s.Every) < window
+	case s.cron != nil:
+		if window <= 0 {
+			window = time.Minute
+		}
+		// Look back over each minute that could have opened a window still in progress
+		for t := now.Truncate(time.Minute); now.Sub(t) < window; t = t.Add(-time.Minute) {
+			if s.cron.matches(t) {
+				return true
+			}
+		}
+		return false
+	default:
+		if start, ok := parseScheduleTime(s.Start, epoch); ok && now.Before(start) {
+			return false
+		}
+		if end, ok := parseScheduleTime(s.End, epoch); ok && !now.Before(end) {
+			return false
+		}
+		return s.Start != "" || s.End != ""
+	}
+}
+
+// currentPhase returns the windows open at now.
+func currentPhase(now time.Time) SchedulePhase {
+	scheduleMutex.RLock()
+	defer scheduleMutex.RUnlock()
+	phase := SchedulePhase{Phase: "normal", Active: []string{}}
+	for _, s := range schedules {
+		if s.active(now, scheduleEpoch) {
+			phase.Active = append(phase.Active, s.Name)
+		}
+	}
+	if len(phase.Active) > 0 {
+		phase.Phase = strings.Join(phase.Active, ",")
+	}
+	return phase
+}
+
+// updateScheduleMetrics sets the echo_schedule_active gauge for every schedule.
+// Each value is set in place, so scrapes never see the gauges missing.
+func updateScheduleMetrics(now time.Time) {
+	scheduleMutex.RLock()
+	defer scheduleMutex.RUnlock()
+	for _, s := range schedules {
+		value := 0.0
+		if s.active(now, scheduleEpoch) {
+			value = 1
+		}
+		scheduleActive.WithLabelValues(s.Name).Set(value)
+	}
+}
+
+// runScheduleTicker keeps the schedule gauge current between requests.
+func runScheduleTicker() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		updateScheduleMetrics(now)
+	}
+}
+
+// processSchedules applies any open windows matching the request. injected is the
+// delay already added by other controls, which latency_factor scales together
+// with the schedule's own latency. It returns
+// true when a scheduled error response was written.
+func processSchedules(w http.ResponseWriter, r *http.Request, injected time.Duration) bool {
+	now := time.Now()
+	scheduleMutex.RLock()
+	var open []Schedule
+	for _, s := range schedules {
+		if matchRoute(s.Path, s.Methods, r) && s.active(now, scheduleEpoch) {
+			open = append(open, s)
+		}
+	}
+	scheduleMutex.RUnlock()
+
+	rnd := requestRand(r)
+	for _, s := range open {
+		w.Header().Add("X-Echo-Schedule", s.Name)
+		if s.Latency != "" || s.LatencyFactor > 0 {
+			factor := s.LatencyFactor
+			if factor <= 0 {
+				factor = 1
+			}
+			base, _ := parseDelaySpec(s.Latency, rnd)
+			extra := time.Duration(float64(injected+base)*factor) - injected
+			if extra > maxInjectedDelay {
+				extra = maxInjectedDelay
+			}
+			if extra > 0 {
+				log.Printf("Schedule %s: adding %v latency", s.Name, extra)
+				time.Sleep(extra)
+			}
+		}
+		rate := s.Rate
+		if rate == 0 {
+			rate = 100
+		}
+		if s.Status >= 100 && s.Status <= 599 && rollPercent(rnd, rate) {
+			log.Printf("Schedule %s: injecting %d for %s", s.Name, s.Status, r.URL.Path)
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(s.Status)
+			w.Write([]byte(fmt.Sprintf("Scheduled outage %s: %d", s.Name, s.Status)))
+			chaosErrors.WithLabelValues("schedule").Inc()
+			return true
+		}
+	}
+	return false
+}
+
+// cronSpec holds the allowed values of each of the five cron fields.
+type cronSpec struct {
+	minute, hour, dom, month, dow map[int]bool
+	domAny, dowAny                bool
+}
+
+// parseCron parses a standard 5-field cron expression supporting *, lists,
+// ranges and steps (e.g. "*/5 9-17 * * 1-5").
+func parseCron(expr string) (cronSpec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return cronSpec{}, fmt.Errorf("cron %q must have 5 fields", expr)
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+	var sets [5]map[int]bool
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return cronSpec{}, fmt.Errorf("cron %q: %w", expr, err)
+		}
+		sets[i] = set
+	}
+	return cronSpec{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domAny: fields[2] == "*", dowAny: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, lo, hi int) (map[int]bool, error) {
+	set := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid step %q", part)
+			}
+			step = n
+		}
+		from, to := lo, hi
+		if rangePart != "*" {
+			a, b, isRange := strings.Cut(rangePart, "-")
+			var err1, err2 error
+			from, err1 = strconv.Atoi(a)
+			to, err2 = from, nil
+			if isRange {
+				to, err2 = strconv.Atoi(b)
+			} else if hasStep {
+				to = hi
+			}
+			if err1 != nil || err2 != nil || from < lo || to > hi || from > to {
+				return nil, fmt.Errorf("invalid field %q", part)
+			}
+		}
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// matches reports whether t (truncated to the minute) is a firing time.
+func (c cronSpec) matches(t time.Time) bool {
+	if !c.minute[t.Minute()] || !c.hour[t.Hour()] || !c.month[int(t.Month())] {
+		return false
+	}
+	// As in standard cron, day-of-month and day-of-week are ORed when both are restricted
+	dom, dow := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
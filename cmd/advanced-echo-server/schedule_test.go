@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestScheduleWindows(t *testing.T) {
+	epoch := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	recurring := Schedule{Name: "blip", Every: "5m", For: "30s"}
+	oneShot := Schedule{Name: "degrade", Start: "t+60s", End: "t+120s"}
+	cron := Schedule{Name: "nightly", Cron: "0 2 * * *", For: "10m"}
+	spec, _ := parseCron(cron.Cron)
+	cron.cron = &spec
+
+	cases := []struct {
+		s      Schedule
+		offset time.Duration
+		want   bool
+	}{
+		{recurring, 10 * time.Second, true},
+		{recurring, 45 * time.Second, false},
+		{recurring, 5*time.Minute + 29*time.Second, true},
+		{oneShot, 59 * time.Second, false},
+		{oneShot, 90 * time.Second, true},
+		{oneShot, 120 * time.Second, false},
+		{cron, 14*time.Hour + 5*time.Minute, true},   // 02:05 next day
+		{cron, 14*time.Hour + 10*time.Minute, false}, // 02:10, window over
+		{cron, 13 * time.Hour, false},
+	}
+	for _, tc := range cases {
+		if got := tc.s.active(epoch.Add(tc.offset), epoch); got != tc.want {
+			t.Errorf("%s at +%v: active=%v, want %v", tc.s.Name, tc.offset, got, tc.want)
+		}
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	spec, err := parseCron("*/15 9-17 * * 1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	monday := time.Date(2025, 1, 6, 9, 30, 0, 0, time.UTC)
+	if !spec.matches(monday) || spec.matches(monday.Add(time.Minute)) || spec.matches(monday.AddDate(0, 0, 5)) {
+		t.Error("cron matching disagrees with */15 9-17 * * 1-5")
+	}
+	for _, bad := range []string{"* * * *", "61 * * * *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := parseCron(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestScheduledOutageAndPhase(t *testing.T) {
+	setupTest()
+	if err := setSchedules([]Schedule{
+		{Name: "api-outage", Path: "/api/*", Every: "5m", For: "1m", Status: 503},
+		{Name: "later", Start: "t+1h", Status: 500},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	router := setupRoutes()
+
+	req, _ := http.NewRequest("GET", "/api/orders", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("X-Echo-Schedule") != "api-outage" {
+		t.Errorf("expected scheduled 503, got %d (%q)", rr.Code, rr.Header().Get("X-Echo-Schedule"))
+	}
+	req, _ = http.NewRequest("GET", "/other", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("unscheduled path should succeed, got %d", rr.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/info", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var info struct {
+		Schedule SchedulePhase `json:"schedule"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &info)
+	if info.Schedule.Phase != "api-outage" || len(info.Schedule.Active) != 1 {
+		t.Errorf("unexpected phase in /info: %+v", info.Schedule)
+	}
+
+	if v := testutil.ToFloat64(scheduleActive.WithLabelValues("api-outage")); v != 1 {
+		t.Errorf("expected api-outage gauge 1, got %v", v)
+	}
+	if v := testutil.ToFloat64(scheduleActive.WithLabelValues("later")); v != 0 {
+		t.Errorf("expected later gauge 0, got %v", v)
+	}
+}
+
+func TestScheduleLatencyFactor(t *testing.T) {
+	setupTest()
+	setSchedules([]Schedule{{Name: "slow", Start: "t+0", Latency: "10ms", LatencyFactor: 3}})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Echo-Delay", "30ms")
+	start := time.Now()
+	http.HandlerFunc(echoHandler).ServeHTTP(httptest.NewRecorder(), req)
+	if dur := time.Since(start); dur < 120*time.Millisecond {
+		t.Errorf("expected 3x the latency plus injected delay, took %v", dur)
+	}
+
+	// A factor on its own degrades the delay injected by other controls
+	if err := setSchedules([]Schedule{{Name: "degrade", Start: "t+0", LatencyFactor: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	start = time.Now()
+	http.HandlerFunc(echoHandler).ServeHTTP(httptest.NewRecorder(), req)
+	if dur := time.Since(start); dur < 90*time.Millisecond {
+		t.Errorf("expected 3x the injected delay, took %v", dur)
+	}
+
+	if err := setSchedules([]Schedule{{Name: "bad", Start: "t+0", LatencyFactor: -1}}); err == nil {
+		t.Error("negative latency_factor should be rejected")
+	}
+}
+
+func TestScheduleGaugesFollowReplacement(t *testing.T) {
+	setupTest()
+	setSchedules([]Schedule{{Name: "old", Start: "t+0"}, {Name: "kept", Cron: "* * * * *"}})
+	if v := testutil.ToFloat64(scheduleActive.WithLabelValues("kept")); v != 1 {
+		t.Errorf("pre-parsed cron window should be open, gauge = %v", v)
+	}
+	setSchedules([]Schedule{{Name: "kept", Start: "t+1h"}})
+	if n := testutil.CollectAndCount(scheduleActive); n != 1 {
+		t.Errorf("replaced schedules should leave one gauge, got %d", n)
+	}
+	if v := testutil.ToFloat64(scheduleActive.WithLabelValues("kept")); v != 0 {
+		t.Errorf("kept gauge should be updated in place, got %v", v)
+	}
+}
+
+func TestSchedulesHandlerRejectsInvalid(t *testing.T) {
+	setupTest()
+	req, _ := http.NewRequest("POST", "/schedules", strings.NewReader(`[{"name":"x","every":"5m"}]`))
+	rr := httptest.NewRecorder()
+	schedulesHandler(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for schedule without a window, got %d", rr.Code)
+	}
+}
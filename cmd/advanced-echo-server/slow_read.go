@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SlowReadRoute configures throttled request-body consumption for matching paths.
+type SlowReadRoute struct {
+	Path    string   `yaml:"path" json:"path"`
+	Methods []string `yaml:"methods,omitempty" json:"methods,omitempty"`
+	Rate    string   `yaml:"rate,omitempty" json:"rate,omitempty"`
+	Pause   string   `yaml:"pause,omitempty" json:"pause,omitempty"`
+	Limit   string   `yaml:"limit,omitempty" json:"limit,omitempty"`
+}
+
+var (
+	slowReadRoutes []SlowReadRoute
+	slowReadMutex  sync.RWMutex
+)
+
+// slowReadChunk is the read size used when a rate or pause is in effect.
+const slowReadChunk = 1024
+
+// readShape controls how fast the request body is consumed.
+type readShape struct {
+	rate  int64         // bytes per second
+	pause time.Duration // pause between reads
+	limit int64         // stop reading after this many bytes; -1 for no limit
+}
+
+func (s readShape) active() bool {
+	return s.rate > 0 || s.pause > 0 || s.limit >= 0
+}
+
+// loadSlowReadRoutes reads per-route slow read settings from a YAML file.
+func loadSlowReadRoutes(path string) {
+	if routes, ok := loadConfigList[SlowReadRoute](path, "slow read"); ok {
+		setSlowReadRoutes(routes)
+	}
+}
+
+// setSlowReadRoutes installs the per-route slow read settings.
+func setSlowReadRoutes(routes []SlowReadRoute) error {
+	slowReadMutex.Lock()
+	slowReadRoutes = routes
+	slowReadMutex.Unlock()
+	return nil
+}
+
+// slowReadHandler lists (GET) or replaces (POST) the slow read routes at runtime.
+func slowReadHandler(w http.ResponseWriter, r *http.Request) {
+	configListHandler(w, r, "slow read routes", func() []SlowReadRoute {
+		slowReadMutex.RLock()
+		defer slowReadMutex.RUnlock()
+		return slowReadRoutes
+	}, setSlowReadRoutes)
+}
+
+// readShapeFor resolves body read controls from headers, then the first matching route.
+func readShapeFor(r *http.Request) readShape {
+	var route SlowReadRoute
+	slowReadMutex.RLock()
+	for _, candidate := range slowReadRoutes {
+		if matchRoute(candidate.Path, candidate.Methods, r) {
+			route = candidate
+			break
+		}
+	}
+	slowReadMutex.RUnlock()
+
+	pick := func(header, fallback string) string {
+		if v := r.Header.Get(header); v != "" {
+			return v
+		}
+		return fallback
+	}
+	shape := readShape{
+		rate:  parseByteSize(pick("X-Echo-Read-Rate", route.Rate)),
+		pause: parseDurationValue(pick("X-Echo-Read-Pause", route.Pause)),
+		limit: -1,
+	}
+	if limit := pick("X-Echo-Read-Limit", route.Limit); limit != "" {
+		if n, err := strconv.ParseInt(limit, 10, 64); err == nil && n >= 0 {
+			shape.limit = n
+		} else if n := parseByteSize(limit); n > 0 {
+			shape.limit = n
+		}
+	}
+	return shape
+}
+
+// slowReader paces reads from the request body. It returns io.EOF once the
+// limit is reached, leaving the rest of the body unread on the connection.
+type slowReader struct {
+	ctx   context.Context
+	src   io.Reader
+	shape readShape
+	read  int64
+	start time.Time
+}
+
+func newSlowReader(ctx context.Context, src io.Reader, shape readShape) *slowReader {
+	return &slowReader{ctx: ctx, src: src, shape: shape, start: time.Now()}
+}
+
+func (s *slowReader) Read(p []byte) (int, error) {
+	if s.shape.limit >= 0 && s.read >= s.shape.limit {
+		return 0, io.EOF
+	}
+	if s.read > 0 && !sleepContext(s.ctx, s.shape.pause) {
+		return 0, s.ctx.Err()
+	}
+	if s.shape.rate > 0 || s.shape.pause > 0 {
+		p = p[:min(len(p), slowReadChunk)]
+	}
+	if s.shape.limit >= 0 {
+		p = p[:min(int64(len(p)), s.shape.limit-s.read)]
+	}
+	n, err := s.src.Read(p)
+	s.read += int64(n)
+	if s.shape.rate > 0 {
+		// Sleep until the bytes read so far fit within the configured rate
+		due := time.Duration(s.read * int64(time.Second) / s.shape.rate)
+		if !sleepContext(s.ctx, due-time.Since(s.start)) {
+			return n, s.ctx.Err()
+		}
+	}
+	return n, err
+}
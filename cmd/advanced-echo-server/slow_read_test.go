@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlowReadRateAndLimit(t *testing.T) {
+	setupTest()
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(strings.Repeat("r", 1000)))
+	req.Header.Set("X-Echo-Read-Rate", "2000")
+	rr := httptest.NewRecorder()
+	start := time.Now()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	dur := time.Since(start)
+	if rr.Body.Len() != 1000 || rr.Header().Get("X-Echo-Read-Bytes") != "1000" {
+		t.Fatalf("unexpected rate-limited echo: %d bytes %v", rr.Body.Len(), rr.Header())
+	}
+	if dur < 400*time.Millisecond || dur > 800*time.Millisecond {
+		t.Errorf("1000 bytes at 2000 B/s should take ~500ms, took %v", dur)
+	}
+
+	req2, _ := http.NewRequest("POST", "/", strings.NewReader("abcdefghij"))
+	req2.Header.Set("X-Echo-Read-Limit", "4")
+	rr2 := httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr2, req2)
+	if rr2.Body.String() != "abcd" || rr2.Header().Get("X-Echo-Read-Bytes") != "4" {
+		t.Errorf("read limit not applied: %q %v", rr2.Body.String(), rr2.Header())
+	}
+}
+
+func TestSlowReadRouteConfig(t *testing.T) {
+	setupTest()
+	slowReadRoutes = []SlowReadRoute{{Path: "/upload/*", Methods: []string{"PUT"}, Pause: "50ms"}}
+
+	req, _ := http.NewRequest("PUT", "/upload/file", strings.NewReader(strings.Repeat("p", 3000)))
+	rr := httptest.NewRecorder()
+	start := time.Now()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	if dur := time.Since(start); dur < 100*time.Millisecond {
+		t.Errorf("route pause not applied, took %v", dur)
+	}
+	if rr.Body.Len() != 3000 {
+		t.Errorf("expected full body echoed, got %d bytes", rr.Body.Len())
+	}
+
+	// Other methods are not affected
+	req2, _ := http.NewRequest("POST", "/upload/file", strings.NewReader("x"))
+	rr2 := httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr2, req2)
+	if rr2.Header().Get("X-Echo-Read-Bytes") != "" {
+		t.Errorf("route should only match PUT")
+	}
+}
+
+func TestSlowReadRoutesEndpoint(t *testing.T) {
+	setupTest()
+	router := setupRoutes()
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/slow-read", strings.NewReader(`[{"path": "/upload/*", "limit": "4"}]`))
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected slow read routes to be accepted, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/upload/file", strings.NewReader("abcdefghij"))
+	router.ServeHTTP(rr, req)
+	if rr.Body.String() != "abcd" {
+		t.Errorf("posted route should limit the read, got %q", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/slow-read", nil)
+	router.ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), `"/upload/*"`) {
+		t.Errorf("expected the route to be listed, got %s", rr.Body.String())
+	}
+}
+
+func TestSlowReadZeroLimitSkipsContinue(t *testing.T) {
+	setupTest()
+	server := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	io.WriteString(conn, "POST / HTTP/1.1\r\nHost: test\r\nContent-Length: 5\r\nExpect: 100-continue\r\nX-Echo-Read-Limit: 0\r\n\r\n")
+	status, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(status, "HTTP/1.1 200") {
+		t.Errorf("expected final response without 100 Continue, got %q", status)
+	}
+}
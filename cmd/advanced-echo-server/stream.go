@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bounds for X-Echo-Stream so a single request cannot hold a connection forever.
+const (
+	maxStreamChunks    = 100000
+	maxStreamInterval  = 60 * time.Second
+	maxStreamChunkSize = 1 << 20
+)
+
+// streamSpec describes a chunked response stream.
+type streamSpec struct {
+	count    int           // number of chunks
+	interval time.Duration // pause between chunks
+	size     int           // bytes per chunk, cycling the body; 0 splits the body evenly
+	ndjson   bool          // frame each chunk as one JSON line
+	abort    int           // drop the connection after this many chunks; 0 ends cleanly
+}
+
+// parseStreamSpec parses "10,interval=200ms,size=32,format=ndjson,abort=5". The
+// leading chunk count is required; abort without a value aborts after the last chunk.
+func parseStreamSpec(spec string) (streamSpec, bool) {
+	parts := strings.Split(spec, ",")
+	count, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || count <= 0 {
+		return streamSpec{}, false
+	}
+	s := streamSpec{count: min(count, maxStreamChunks), interval: 100 * time.Millisecond}
+	for _, part := range parts[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch strings.ToLower(key) {
+		case "interval":
+			s.interval = min(parseDurationValue(value), maxStreamInterval)
+		case "size":
+			s.size = int(min(parseByteSize(value), maxStreamChunkSize))
+		case "format":
+			s.ndjson = strings.EqualFold(value, "ndjson")
+		case "abort":
+			s.abort = s.count
+			if n, err := strconv.Atoi(value); err == nil && n >= 0 && n < s.count {
+				s.abort = n
+			}
+		}
+	}
+	return s, true
+}
+
+// streamSpecFor resolves X-Echo-Stream / ECHO_STREAM, then the scenario response.
+func streamSpecFor(r *http.Request, resp Response) (streamSpec, bool) {
+	spec := getHeaderOrEnv(r, "X-Echo-Stream", "ECHO_STREAM")
+	if spec == "" {
+		spec = resp.Stream
+	}
+	if spec == "" {
+		return streamSpec{}, false
+	}
+	return parseStreamSpec(spec)
+}
+
+// chunk returns the i-th piece of body: size bytes cycling through it, or an even
+// share of it when no size is set.
+func (s streamSpec) chunk(body []byte, i int) []byte {
+	if s.size > 0 {
+		if len(body) == 0 {
+			body = []byte("echo ")
+		}
+		piece := make([]byte, s.size)
+		repeatPayload{pattern: body, size: int64(s.size * s.count)}.ReadAt(piece, int64(i*s.size))
+		return piece
+	}
+	return body[i*len(body)/s.count : (i+1)*len(body)/s.count]
+}
+
+// writeStream sends body as a chunked stream, flushing every chunk and pausing
+// between them, optionally as NDJSON and optionally ending with a dropped connection.
+func writeStream(w http.ResponseWriter, r *http.Request, status int, body []byte, s streamSpec) {
+	rc := http.NewResponseController(w)
+	if s.ndjson {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Del("Content-Length")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Echo-Stream-Chunks", strconv.Itoa(s.count))
+	w.WriteHeader(status)
+	rc.Flush()
+	log.Printf("Stream: %d chunks every %v to %s", s.count, s.interval, r.RemoteAddr)
+
+	for i := 0; i < s.count; i++ {
+		if s.abort > 0 && i == s.abort {
+			break
+		}
+		if i > 0 && !sleepContext(r.Context(), s.interval) {
+			return
+		}
+		piece := s.chunk(body, i)
+		if s.ndjson {
+			line, _ := json.Marshal(map[string]interface{}{"seq": i, "data": string(piece), "done": i == s.count-1})
+			piece = append(line, '\n')
+		}
+		if _, err := w.Write(piece); err != nil {
+			return
+		}
+		rc.Flush()
+	}
+	if s.abort > 0 {
+		log.Printf("Stream: aborting after %d chunks", s.abort)
+		chaosErrors.WithLabelValues("stream_abort").Inc()
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStreamSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want streamSpec
+		ok   bool
+	}{
+		{"5", streamSpec{count: 5, interval: 100 * time.Millisecond}, true},
+		{"10,interval=1s,size=2k,format=ndjson", streamSpec{count: 10, interval: time.Second, size: 2048, ndjson: true}, true},
+		{"4,abort", streamSpec{count: 4, interval: 100 * time.Millisecond, abort: 4}, true},
+		{"4,abort=2,interval=0", streamSpec{count: 4, abort: 2}, true},
+		{"0", streamSpec{}, false},
+		{"fast", streamSpec{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseStreamSpec(tt.spec)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseStreamSpec(%q) = %+v, %v; want %+v, %v", tt.spec, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestStreamedEcho(t *testing.T) {
+	setupTest()
+	server := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL+"/stream", strings.NewReader("abcdefghij"))
+	req.Header.Set("X-Echo-Stream", "5,interval=50ms")
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != "abcdefghij" {
+		t.Errorf("unexpected body %q: %v", body, err)
+	}
+	if resp.ContentLength != -1 || resp.Header.Get("X-Echo-Stream-Chunks") != "5" {
+		t.Errorf("expected a chunked 5-chunk stream, got length %d", resp.ContentLength)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("5 chunks at 50ms took only %v", elapsed)
+	}
+}
+
+func TestStreamNDJSONAndAbort(t *testing.T) {
+	setupTest()
+	server := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer server.Close()
+
+	get := func(spec string) (*http.Response, []map[string]interface{}, error) {
+		req, _ := http.NewRequest("GET", server.URL+"/feed", nil)
+		req.Header.Set("X-Echo-Stream", spec)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var lines []map[string]interface{}
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var line map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				t.Fatalf("invalid NDJSON line %q: %v", scanner.Text(), err)
+			}
+			lines = append(lines, line)
+		}
+		return resp, lines, scanner.Err()
+	}
+
+	resp, lines, err := get("3,interval=1ms,size=4,format=ndjson")
+	if err != nil || len(lines) != 3 || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("expected 3 NDJSON lines, got %d (%v)", len(lines), err)
+	}
+	if lines[2]["done"] != true || lines[1]["done"] != false || len(lines[0]["data"].(string)) != 4 {
+		t.Errorf("unexpected lines %v", lines)
+	}
+
+	_, lines, err = get("4,interval=1ms,format=ndjson,abort=2")
+	if err == nil || len(lines) != 2 {
+		t.Errorf("expected an abrupt end after 2 lines, got %d lines and err %v", len(lines), err)
+	}
+}
+
+func TestScenarioStream(t *testing.T) {
+	setupTest()
+	scenarios.Store("/tokens", []Response{{Status: 200, Body: "one two three ", Stream: "3,interval=1ms"}})
+	server := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/tokens")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "one two three " || resp.Header.Get("X-Echo-Stream-Chunks") != "3" {
+		t.Errorf("unexpected scenario stream %q", body)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"net/http"
 	"os"
 	"sync"
 	"sync/atomic"
@@ -37,6 +38,27 @@ func setupTest() {
 	historyMutex.Unlock()
 	atomic.StoreUint64(&requestCounter, 0) // thread-safe reset
 	rateLimiter = nil
+	slowReadMutex.Lock()
+	slowReadRoutes = nil
+	slowReadMutex.Unlock()
+	retryMutex.Lock()
+	retryAttempts = map[string]*retryAttempt{}
+	retryMutex.Unlock()
+	scheduleMutex.Lock()
+	schedules = nil
+	scheduleMutex.Unlock()
+	rulesMutex.Lock()
+	routeRules = nil
+	rulesMutex.Unlock()
+	proxyFaultMutex.Lock()
+	proxyFaultRules = nil
+	proxyFaultMutex.Unlock()
+	cassetteMutex.Lock()
+	cassettes = map[string]*loadedCassette{}
+	cassetteMutex.Unlock()
+	graphqlSchemaMutex.Lock()
+	graphqlSchema = nil
+	graphqlSchemaMutex.Unlock()
 	config = Config{
 		Port:           "8080",
 		EnableCORS:     true,
@@ -72,7 +94,50 @@ func setupTest() {
 		},
 		[]string{"type"},
 	)
-	testRegistry.MustRegister(requestTotal, requestLatency, chaosErrors)
+	proxyFaults = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "echo_proxy_faults_total",
+			Help: "Total number of faults injected into proxied traffic",
+		},
+		[]string{"direction", "type"},
+	)
+	mirrorRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "echo_mirror_requests_total",
+			Help: "Total number of requests mirrored to shadow targets",
+		},
+		[]string{"target", "status"},
+	)
+	mirrorLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "echo_mirror_duration_seconds",
+			Help:    "Shadow target latency in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 15),
+		},
+		[]string{"target"},
+	)
+	scheduleActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "echo_schedule_active",
+			Help: "Whether each outage schedule window is currently open (1) or not (0)",
+		},
+		[]string{"schedule"},
+	)
+	rawConnections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "echo_raw_connections_total",
+			Help: "Total number of raw TCP connections accepted and UDP datagrams received",
+		},
+		[]string{"protocol"},
+	)
+	rawBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "echo_raw_bytes_total",
+			Help: "Total bytes received (in) and echoed (out) by the raw listeners",
+		},
+		[]string{"protocol", "direction"},
+	)
+	testRegistry.MustRegister(requestTotal, requestLatency, chaosErrors, proxyFaults, mirrorRequests, mirrorLatency, scheduleActive, rawConnections, rawBytes)
 }
 
 func TestMain(m *testing.M) {
@@ -80,3 +145,16 @@ func TestMain(m *testing.M) {
 	setupTest()
 	os.Exit(m.Run())
 }
+
+// newTrackedServer starts a test server whose Close also waits for handlers that
+// outlive their connection (hijacked or dropped), so they cannot race the next setupTest.
+func newTrackedServer(t *testing.T, handler http.Handler) *httptest.Server {
+	var inflight sync.WaitGroup
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inflight.Add(1)
+		defer inflight.Done()
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(inflight.Wait)
+	return server
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// streamChunk bounds the buffer used when copying a paced body.
+const streamChunk = 32 * 1024
+
+// shapeTick is the write interval used when spreading a body over a duration or rate.
+const shapeTick = 100 * time.Millisecond
+
+// bodyShape controls how a response body is paced onto the wire.
+type bodyShape struct {
+	rate      int64         // bytes per second
+	ttfb      time.Duration // wait before the headers and first bytes
+	duration  time.Duration // total time from first byte to last
+	dripBytes int           // bytes written per drip
+	dripEvery time.Duration // interval between drips
+}
+
+func (s bodyShape) active() bool {
+	return s.rate > 0 || s.ttfb > 0 || s.duration > 0 || (s.dripBytes > 0 && s.dripEvery > 0)
+}
+
+// parseByteSize parses sizes such as "512", "64k", "64kb" or "2m" into bytes.
+func parseByteSize(s string) int64 {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.TrimSuffix(s, "b")
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(s, "k"):
+		multiplier, s = 1024, strings.TrimSuffix(s, "k")
+	case strings.HasSuffix(s, "m"):
+		multiplier, s = 1024*1024, strings.TrimSuffix(s, "m")
+	case strings.HasSuffix(s, "g"):
+		multiplier, s = 1024*1024*1024, strings.TrimSuffix(s, "g")
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n * multiplier
+}
+
+// parseDurationValue accepts Go durations ("1.5s") or bare milliseconds ("250").
+func parseDurationValue(s string) time.Duration {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+	if ms, err := strconv.Atoi(s); err == nil && ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return d
+	}
+	return 0
+}
+
+// bodyShapeFor resolves pacing controls from headers, then environment, then the
+// scenario response (pass an empty Response for plain echo).
+func bodyShapeFor(r *http.Request, resp Response) bodyShape {
+	pick := func(header, env, fallback string) string {
+		if v := getHeaderOrEnv(r, header, env); v != "" {
+			return v
+		}
+		return fallback
+	}
+	shape := bodyShape{
+		rate:     parseByteSize(pick("X-Echo-Throttle", "ECHO_THROTTLE", resp.Throttle)),
+		ttfb:     parseDurationValue(pick("X-Echo-TTFB", "ECHO_TTFB", resp.TTFB)),
+		duration: parseDurationValue(pick("X-Echo-Duration", "ECHO_DURATION", resp.Duration)),
+	}
+	if drip := pick("X-Echo-Drip", "ECHO_DRIP", resp.Drip); drip != "" {
+		if size, every, ok := strings.Cut(drip, ","); ok {
+			shape.dripBytes = int(parseByteSize(size))
+			shape.dripEvery = parseDurationValue(every)
+		}
+	}
+	return shape
+}
+
+// sleepContext sleeps for d or until ctx is done, reporting whether the full sleep elapsed.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// chunking returns the chunk size and interval for the shape. Drip wins over
+// duration, which wins over rate.
+func (s bodyShape) chunking(size int) (int, time.Duration) {
+	switch {
+	case s.dripBytes > 0 && s.dripEvery > 0:
+		return s.dripBytes, s.dripEvery
+	case s.duration > 0:
+		spread := s.duration - s.ttfb
+		ticks := int(spread / shapeTick)
+		if ticks < 1 || size == 0 {
+			return size, 0
+		}
+		chunk := (size + ticks - 1) / ticks
+		return chunk, shapeTick
+	case s.rate > 0:
+		chunk := int(s.rate * int64(shapeTick) / int64(time.Second))
+		if chunk < 1 {
+			// Very slow rates send a byte at a time with a longer gap
+			return 1, time.Duration(int64(time.Second) / s.rate)
+		}
+		return chunk, shapeTick
+	}
+	return size, 0
+}
+
+// writeShapedBody writes status and body, pacing the body according to shape.
+func writeShapedBody(w http.ResponseWriter, r *http.Request, status int, body []byte, shape bodyShape) {
+	if !shape.active() {
+		w.WriteHeader(status)
+		w.Write(body)
+		return
+	}
+	writeShapedStream(w, r, status, bytes.NewReader(body), int64(len(body)), shape)
+}
+
+// writeShapedStream copies size bytes from src, pacing them according to shape.
+// The full Content-Length is declared up front so clients can track progress,
+// unless trailers follow the body.
+func writeShapedStream(w http.ResponseWriter, r *http.Request, status int, src io.Reader, size int64, shape bodyShape) {
+	if !sleepContext(r.Context(), shape.ttfb) {
+		return
+	}
+	if !wantsTrailers(r) {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	w.WriteHeader(status)
+	if !shape.active() {
+		io.CopyN(w, src, size)
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	chunk, interval := shape.chunking(int(size))
+	if chunk <= 0 {
+		chunk = int(size)
+	}
+	buf := make([]byte, min(chunk, streamChunk))
+	for written := int64(0); written < size; {
+		// Chunks larger than the copy buffer are written in pieces before the pause
+		for sent := 0; sent < chunk && written < size; {
+			n, err := src.Read(buf[:min(len(buf), chunk-sent, int(size-written))])
+			if n > 0 {
+				if _, werr := w.Write(buf[:n]); werr != nil {
+					return
+				}
+				sent += n
+				written += int64(n)
+			}
+			if err != nil {
+				return
+			}
+		}
+		flush()
+		if written < size && !sleepContext(r.Context(), interval) {
+			return
+		}
+	}
+	flush()
+}
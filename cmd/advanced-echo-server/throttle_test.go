@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseByteSize(t *testing.T) {
+	cases := map[string]int64{"512": 512, "64k": 65536, "64KB": 65536, "2m": 2097152, "bad": 0, "-1": 0}
+	for in, want := range cases {
+		if got := parseByteSize(in); got != want {
+			t.Errorf("parseByteSize(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestThrottledResponseBody(t *testing.T) {
+	setupTest()
+	body := strings.Repeat("x", 300)
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("X-Echo-Throttle", "1000")
+	rr := httptest.NewRecorder()
+	start := time.Now()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	dur := time.Since(start)
+	if rr.Body.String() != body {
+		t.Fatalf("throttled body mismatch: got %d bytes", rr.Body.Len())
+	}
+	if rr.Header().Get("Content-Length") != "300" {
+		t.Errorf("expected Content-Length 300, got %q", rr.Header().Get("Content-Length"))
+	}
+	// 300 bytes at 1000 B/s is three 100-byte chunks with two pauses
+	if dur < 180*time.Millisecond || dur > 400*time.Millisecond {
+		t.Errorf("unexpected throttle duration: %v", dur)
+	}
+}
+
+func TestDripAndTTFB(t *testing.T) {
+	setupTest()
+	req, _ := http.NewRequest("POST", "/", strings.NewReader("0123456789abcdefghij"))
+	req.Header.Set("X-Echo-Drip", "5,20ms")
+	req.Header.Set("X-Echo-TTFB", "50ms")
+	rr := httptest.NewRecorder()
+	start := time.Now()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	dur := time.Since(start)
+	if rr.Body.String() != "0123456789abcdefghij" {
+		t.Fatalf("drip body mismatch: %q", rr.Body.String())
+	}
+	// 50ms TTFB plus three 20ms drip gaps
+	if dur < 100*time.Millisecond || dur > 250*time.Millisecond {
+		t.Errorf("unexpected drip duration: %v", dur)
+	}
+}
+
+func TestScenarioDurationShaping(t *testing.T) {
+	setupTest()
+	scenarios.Store("/slow-body", []Response{{Status: 200, Body: strings.Repeat("y", 40), Duration: "300ms"}})
+	scenarioIndex.Store("/slow-body", 0)
+
+	req, _ := http.NewRequest("GET", "/slow-body", nil)
+	rr := httptest.NewRecorder()
+	start := time.Now()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	dur := time.Since(start)
+	if rr.Body.Len() != 40 || rr.Header().Get("X-Echo-Scenario") != "true" {
+		t.Fatalf("unexpected scenario response: %d bytes %v", rr.Body.Len(), rr.Header())
+	}
+	if dur < 180*time.Millisecond || dur > 450*time.Millisecond {
+		t.Errorf("scenario duration not applied: %v", dur)
+	}
+}
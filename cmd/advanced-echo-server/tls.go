@@ -11,6 +11,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -65,14 +66,24 @@ func generateSelfSignedCert() {
 	log.Println("Successfully generated self-signed certificate and key.")
 }
 
-// startServer starts the provided HTTP server with TLS if enabled in config.
-// It returns any error from ListenAndServe or ListenAndServeTLS.
-func startServer(server *http.Server) error {
-	if config.EnableTLS {
+// certOnce guards certificate generation shared by the HTTP and gRPC listeners.
+var certOnce sync.Once
+
+// ensureCertificate generates a self-signed certificate if none exists yet.
+func ensureCertificate() {
+	certOnce.Do(func() {
 		if _, err := os.Stat(config.CertFile); os.IsNotExist(err) {
 			log.Println("Certificate file not found. Generating a self-signed certificate...")
 			generateSelfSignedCert()
 		}
+	})
+}
+
+// startServer starts the provided HTTP server with TLS if enabled in config.
+// It returns any error from ListenAndServe or ListenAndServeTLS.
+func startServer(server *http.Server) error {
+	if config.EnableTLS {
+		ensureCertificate()
 		log.Printf("Starting HTTPS server with cert: %s", config.CertFile)
 		return server.ListenAndServeTLS(config.CertFile, config.KeyFile)
 	}
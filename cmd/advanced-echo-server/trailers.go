@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+)
+
+// parseTrailerSpec parses "Name=value,Other=value" into trailer fields.
+func parseTrailerSpec(spec string) http.Header {
+	fields := http.Header{}
+	for _, part := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(part, "=")
+		if name = strings.TrimSpace(name); !ok || name == "" {
+			continue
+		}
+		fields.Add(name, strings.TrimSpace(value))
+	}
+	return fields
+}
+
+// wantsTrailers reports whether the response will carry trailers, in which case
+// it must not declare a Content-Length so HTTP/1.1 falls back to chunked encoding.
+func wantsTrailers(r *http.Request) bool {
+	return len(r.Trailer) > 0 ||
+		getHeaderOrEnv(r, "X-Echo-Trailers", "ECHO_TRAILERS") != "" ||
+		getHeaderOrEnv(r, "X-Echo-Undeclared-Trailers", "ECHO_UNDECLARED_TRAILERS") != "" ||
+		checksumMode(r) == "trailer"
+}
+
+// processTrailers declares the response trailers requested by X-Echo-Trailers /
+// ECHO_TRAILERS and returns a function that fills them in once the body has been
+// written. X-Echo-Undeclared-Trailers / ECHO_UNDECLARED_TRAILERS sends trailers
+// missing from the Trailer header, and request trailers are echoed back as
+// X-Echoed-<Name> trailers. It must run after the request body has been read.
+func processTrailers(w http.ResponseWriter, r *http.Request) func() {
+	declared := parseTrailerSpec(getHeaderOrEnv(r, "X-Echo-Trailers", "ECHO_TRAILERS"))
+	undeclared := parseTrailerSpec(getHeaderOrEnv(r, "X-Echo-Undeclared-Trailers", "ECHO_UNDECLARED_TRAILERS"))
+	for name, values := range r.Trailer {
+		for _, value := range values {
+			declared.Add("X-Echoed-"+name, value)
+		}
+	}
+	if len(declared) == 0 && len(undeclared) == 0 {
+		return func() {}
+	}
+
+	for name := range declared {
+		w.Header().Add("Trailer", name)
+	}
+	return func() {
+		log.Printf("Trailers: %d declared, %d undeclared", len(declared), len(undeclared))
+		for name, values := range declared {
+			w.Header()[http.CanonicalHeaderKey(name)] = values
+		}
+		if len(undeclared) > 0 {
+			// Flushing commits the headers without a Content-Length so HTTP/1.1
+			// switches to chunked encoding, which can carry the late fields
+			if flusher, ok := w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+		}
+		for name, values := range undeclared {
+			w.Header()[http.TrailerPrefix+http.CanonicalHeaderKey(name)] = values
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseTrailers(t *testing.T) {
+	setupTest()
+	server := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer server.Close()
+
+	// declared holds the trailer names announced before the body was read
+	var declared []string
+	get := func(headers map[string]string) *http.Response {
+		req, _ := http.NewRequest("GET", server.URL+"/trailers", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		declared = declared[:0]
+		for name := range resp.Trailer {
+			declared = append(declared, name)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := get(map[string]string{"X-Echo-Trailers": "Grpc-Status=0, Grpc-Message=ok"})
+	if len(declared) != 2 {
+		t.Errorf("expected two declared trailers, got %v", declared)
+	}
+	if resp.Trailer.Get("Grpc-Status") != "0" || resp.Trailer.Get("Grpc-Message") != "ok" {
+		t.Errorf("unexpected trailers %v", resp.Trailer)
+	}
+
+	resp = get(map[string]string{"X-Echo-Undeclared-Trailers": "X-Late=1"})
+	if len(declared) != 0 || resp.Trailer.Get("X-Late") != "1" {
+		t.Errorf("expected only an undeclared X-Late trailer, got declared %v trailers %v", declared, resp.Trailer)
+	}
+
+	resp = get(map[string]string{"X-Echo-Trailers": "X-Sum=abc", "X-Echo-Response-Size": "4k"})
+	if resp.ContentLength != -1 || resp.Trailer.Get("X-Sum") != "abc" {
+		t.Errorf("generated payload should be chunked with trailers, got length %d trailers %v", resp.ContentLength, resp.Trailer)
+	}
+}
+
+func TestRequestTrailersEchoed(t *testing.T) {
+	setupTest()
+	server := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL+"/upload", strings.NewReader("payload"))
+	req.ContentLength = -1
+	req.Trailer = http.Header{"X-Client-Sum": {"abc123"}}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "payload" {
+		t.Errorf("unexpected body %q", body)
+	}
+	if got := resp.Trailer.Get("X-Echoed-X-Client-Sum"); got != "abc123" {
+		t.Errorf("expected the request trailer echoed back, got %v", resp.Trailer)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// upstreamClient is shared by the modes that forward traffic to a real backend.
+// Redirects are returned to the caller untouched so they can be recorded or relayed.
+var upstreamClient = &http.Client{
+	Timeout: 30 * time.Second,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// hopHeaders are connection-specific and must not be forwarded by a proxy.
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// upstreamURL joins the upstream base URL with the incoming request path and query.
+func upstreamURL(upstream string, r *http.Request) (string, error) {
+	base, err := url.Parse(upstream)
+	if err != nil {
+		return "", err
+	}
+	if base.Scheme == "" || base.Host == "" {
+		return "", fmt.Errorf("invalid upstream URL %q", upstream)
+	}
+	target := *base
+	target.Path = strings.TrimSuffix(base.Path, "/") + r.URL.Path
+	target.RawPath = ""
+	target.RawQuery = r.URL.RawQuery
+	return target.String(), nil
+}
+
+// forwardRequest sends a copy of r with the given body to upstream and returns the
+// response with its body fully read. X-Echo-* controls are stripped so they only
+// affect this server.
+func forwardRequest(r *http.Request, body []byte, upstream string) (*http.Response, []byte, error) {
+	resp, err := sendUpstream(r, body, upstream)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, respBody, nil
+}
+
+// sendUpstream sends a copy of r with the given body to upstream and returns the
+// response with its body still open, so it can be streamed to the client.
+func sendUpstream(r *http.Request, body []byte, upstream string) (*http.Response, error) {
+	req, err := newUpstreamRequest(r, body, upstream)
+	if err != nil {
+		return nil, err
+	}
+	return upstreamClient.Do(req)
+}
+
+// newUpstreamRequest builds the copy of r that is sent to upstream.
+func newUpstreamRequest(r *http.Request, body []byte, upstream string) (*http.Request, error) {
+	target, err := upstreamURL(upstream, r)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = r.Header.Clone()
+	for _, h := range hopHeaders {
+		req.Header.Del(h)
+	}
+	for name := range req.Header {
+		if strings.HasPrefix(name, "X-Echo-") {
+			req.Header.Del(name)
+		}
+	}
+	if prior := req.Header.Get("X-Forwarded-For"); prior != "" {
+		req.Header.Set("X-Forwarded-For", prior+", "+getClientIP(r))
+	} else {
+		req.Header.Set("X-Forwarded-For", getClientIP(r))
+	}
+	return req, nil
+}
+
+// sendTruncated sends a copy of r that declares a Content-Length of length but
+// carries only body, then half-closes the connection so the upstream sees a real
+// short read. http.Client refuses such requests, so it is written by hand over a
+// dedicated connection. The response is returned with its body still open;
+// closing it closes the connection.
+func sendTruncated(r *http.Request, body []byte, length int64, upstream string) (*http.Response, error) {
+	req, err := newUpstreamRequest(r, body, upstream)
+	if err != nil {
+		return nil, err
+	}
+	// The body is sent without waiting, so there is no interim 100 response to wait for
+	req.Header.Del("Expect")
+
+	ctx, cancel := context.WithTimeout(r.Context(), upstreamClient.Timeout)
+	defer cancel()
+	addr := req.URL.Host
+	if req.URL.Port() == "" {
+		port := "80"
+		if req.URL.Scheme == "https" {
+			port = "443"
+		}
+		addr = net.JoinHostPort(req.URL.Hostname(), port)
+	}
+	var conn net.Conn
+	if req.URL.Scheme == "https" {
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: req.URL.Hostname()}}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(upstreamClient.Timeout))
+
+	bw := bufio.NewWriter(conn)
+	fmt.Fprintf(bw, "%s %s HTTP/1.1\r\nHost: %s\r\nContent-Length: %d\r\nConnection: close\r\n",
+		req.Method, req.URL.RequestURI(), req.URL.Host, length)
+	req.Header.WriteSubset(bw, map[string]bool{"Host": true, "Content-Length": true, "Connection": true})
+	bw.WriteString("\r\n")
+	bw.Write(body)
+	if err := bw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	}
+
+	// Skip any interim 1xx responses until the final one arrives
+	br := bufio.NewReader(conn)
+	for {
+		resp, err := http.ReadResponse(br, req)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if resp.StatusCode >= 200 || resp.StatusCode == http.StatusSwitchingProtocols {
+			resp.Body = connBody{resp.Body, conn}
+			return resp, nil
+		}
+		resp.Body.Close()
+	}
+}
+
+// connBody closes the dedicated connection along with the response body.
+type connBody struct {
+	io.ReadCloser
+	conn net.Conn
+}
+
+func (b connBody) Close() error {
+	b.ReadCloser.Close()
+	return b.conn.Close()
+}
+
+// copyResponseHeaders copies upstream response headers onto w, skipping hop-by-hop
+// headers and Content-Length (which is recomputed on write).
+func copyResponseHeaders(w http.ResponseWriter, header http.Header) {
+	for name, values := range header {
+		if name == "Content-Length" {
+			continue
+		}
+		w.Header()[name] = append([]string(nil), values...)
+	}
+	for _, h := range hopHeaders {
+		w.Header().Del(h)
+	}
+}
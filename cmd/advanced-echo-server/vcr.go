@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// VCR modes
+const (
+	vcrModeRecord      = "record"
+	vcrModePlayback    = "playback"
+	vcrModePassthrough = "passthrough"
+)
+
+// Cassette is a named set of recorded upstream interactions.
+type Cassette struct {
+	Name         string        `yaml:"name" json:"name"`
+	Interactions []Interaction `yaml:"interactions" json:"interactions"`
+}
+
+// Interaction is a single recorded request/response pair.
+type Interaction struct {
+	RecordedAt time.Time        `yaml:"recorded_at" json:"recorded_at"`
+	Request    RecordedRequest  `yaml:"request" json:"request"`
+	Response   RecordedResponse `yaml:"response" json:"response"`
+}
+
+// RecordedRequest is the request half of an interaction.
+type RecordedRequest struct {
+	Method  string      `yaml:"method" json:"method"`
+	Path    string      `yaml:"path" json:"path"`
+	Query   string      `yaml:"query,omitempty" json:"query,omitempty"`
+	Headers http.Header `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Body    string      `yaml:"body,omitempty" json:"body,omitempty"`
+}
+
+// RecordedResponse is the response half of an interaction.
+type RecordedResponse struct {
+	Status  int         `yaml:"status" json:"status"`
+	Headers http.Header `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Body    string      `yaml:"body,omitempty" json:"body,omitempty"`
+}
+
+// loadedCassette tracks a cassette in memory along with how often each interaction was played.
+type loadedCassette struct {
+	cassette Cassette
+	plays    []int
+}
+
+var (
+	cassettes     = map[string]*loadedCassette{}
+	cassetteMutex sync.Mutex
+
+	cassetteNamePattern = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+)
+
+// cassetteName returns the cassette selected by X-Echo-Cassette, sanitized for use as a file name.
+func cassetteName(r *http.Request) string {
+	name := cassetteNamePattern.ReplaceAllString(r.Header.Get("X-Echo-Cassette"), "_")
+	name = strings.Trim(name, ".")
+	if name == "" {
+		return "default"
+	}
+	return name
+}
+
+func cassettePath(dir, name string) string {
+	return filepath.Join(dir, name+".yaml")
+}
+
+// loadCassette returns the named cassette, reading it from disk on first use.
+// Callers must hold cassetteMutex.
+func loadCassette(dir, name string) *loadedCassette {
+	if c, ok := cassettes[name]; ok {
+		return c
+	}
+	c := &loadedCassette{cassette: Cassette{Name: name}}
+	if data, err := os.ReadFile(cassettePath(dir, name)); err == nil {
+		if err := yaml.Unmarshal(data, &c.cassette); err != nil {
+			log.Printf("Failed to parse cassette %s: %v", name, err)
+		}
+	}
+	c.plays = make([]int, len(c.cassette.Interactions))
+	cassettes[name] = c
+	return c
+}
+
+// saveCassette writes the cassette to disk. Callers must hold cassetteMutex.
+func saveCassette(dir string, c *loadedCassette) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(c.cassette)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cassettePath(dir, c.cassette.Name), data, 0644)
+}
+
+// vcrMatchFields parses the comma-separated ECHO_VCR_MATCH setting.
+func vcrMatchFields(spec string) map[string]bool {
+	fields := map[string]bool{}
+	for _, f := range strings.Split(spec, ",") {
+		if f = strings.ToLower(strings.TrimSpace(f)); f != "" {
+			fields[f] = true
+		}
+	}
+	return fields
+}
+
+// matches reports whether a recorded request matches r on the selected fields.
+func (rr RecordedRequest) matches(r *http.Request, body []byte, fields map[string]bool) bool {
+	if fields["method"] && rr.Method != r.Method {
+		return false
+	}
+	if fields["path"] && rr.Path != r.URL.Path {
+		return false
+	}
+	if fields["query"] && rr.Query != r.URL.Query().Encode() {
+		return false
+	}
+	if fields["body"] && rr.Body != string(body) {
+		return false
+	}
+	return true
+}
+
+// processVCR handles the request in record, playback or passthrough mode.
+// It returns false when VCR mode is disabled.
+func processVCR(w http.ResponseWriter, r *http.Request, body []byte) bool {
+	configLock.RLock()
+	mode := strings.ToLower(config.VCRMode)
+	upstream := config.VCRUpstream
+	dir := config.VCRCassetteDir
+	match := config.VCRMatch
+	configLock.RUnlock()
+
+	switch mode {
+	case vcrModePlayback:
+		playbackInteraction(w, r, body, dir, vcrMatchFields(match))
+		return true
+	case vcrModeRecord, vcrModePassthrough:
+		if upstream == "" {
+			http.Error(w, "VCR "+mode+" mode requires ECHO_VCR_UPSTREAM", http.StatusBadGateway)
+			return true
+		}
+		resp, respBody, err := forwardRequest(r, body, upstream)
+		if err != nil {
+			http.Error(w, "Upstream request failed: "+err.Error(), http.StatusBadGateway)
+			return true
+		}
+		if mode == vcrModeRecord {
+			recordInteraction(r, body, resp, respBody, dir)
+			w.Header().Set("X-Echo-VCR", "recorded")
+		} else {
+			w.Header().Set("X-Echo-VCR", "passthrough")
+		}
+		copyResponseHeaders(w, resp.Header)
+		w.WriteHeader(resp.StatusCode)
+		w.Write(respBody)
+		return true
+	}
+	return false
+}
+
+// redactedValue replaces credentials in recorded headers.
+const redactedValue = "[REDACTED]"
+
+// isSensitiveHeader reports whether a request or response header carries
+// credentials that must not be written to cassettes on disk.
+func isSensitiveHeader(name string) bool {
+	switch name = strings.ToLower(name); name {
+	case "authorization", "proxy-authorization", "cookie", "set-cookie", "x-auth-token":
+		return true
+	}
+	return strings.Contains(name, "api-key") || strings.Contains(name, "apikey")
+}
+
+// redactHeaders copies h with the values of sensitive headers replaced.
+func redactHeaders(h http.Header) http.Header {
+	out := h.Clone()
+	for name, values := range out {
+		if isSensitiveHeader(name) {
+			for i := range values {
+				values[i] = redactedValue
+			}
+		}
+	}
+	return out
+}
+
+// recordInteraction appends the exchange to the selected cassette and persists it.
+func recordInteraction(r *http.Request, body []byte, resp *http.Response, respBody []byte, dir string) {
+	name := cassetteName(r)
+	interaction := Interaction{
+		RecordedAt: time.Now(),
+		Request: RecordedRequest{
+			Method:  r.Method,
+			Path:    r.URL.Path,
+			Query:   r.URL.Query().Encode(),
+			Headers: redactHeaders(r.Header),
+			Body:    string(body),
+		},
+		Response: RecordedResponse{
+			Status:  resp.StatusCode,
+			Headers: redactHeaders(resp.Header),
+			Body:    string(respBody),
+		},
+	}
+
+	cassetteMutex.Lock()
+	defer cassetteMutex.Unlock()
+	c := loadCassette(dir, name)
+	c.cassette.Interactions = append(c.cassette.Interactions, interaction)
+	c.plays = append(c.plays, 0)
+	if err := saveCassette(dir, c); err != nil {
+		log.Printf("Failed to save cassette %s: %v", name, err)
+	}
+}
+
+// playbackInteraction serves the least-played matching interaction, so repeated
+// identical requests replay in recorded order and then cycle.
+func playbackInteraction(w http.ResponseWriter, r *http.Request, body []byte, dir string, fields map[string]bool) {
+	name := cassetteName(r)
+
+	cassetteMutex.Lock()
+	c := loadCassette(dir, name)
+	found := -1
+	for i, in := range c.cassette.Interactions {
+		if !in.Request.matches(r, body, fields) {
+			continue
+		}
+		if found == -1 || c.plays[i] < c.plays[found] {
+			found = i
+		}
+	}
+	var resp RecordedResponse
+	if found >= 0 {
+		c.plays[found]++
+		resp = c.cassette.Interactions[found].Response
+	}
+	cassetteMutex.Unlock()
+
+	if found < 0 {
+		w.Header().Set("X-Echo-VCR", "miss")
+		http.Error(w, fmt.Sprintf("No recorded interaction for %s %s in cassette %q", r.Method, r.URL.Path, name), http.StatusNotFound)
+		return
+	}
+
+	copyResponseHeaders(w, resp.Headers)
+	w.Header().Set("X-Echo-VCR", "playback")
+	w.WriteHeader(resp.Status)
+	w.Write([]byte(resp.Body))
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		for name := range r.Header {
+			if strings.HasPrefix(name, "X-Echo-") {
+				t.Errorf("control header %s leaked upstream", name)
+			}
+		}
+		w.Header().Set("X-Upstream", "real")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("upstream:" + r.Method + " " + r.URL.RequestURI() + " " + string(body)))
+	}))
+}
+
+func setVCRConfig(mode, upstream, dir, match string) {
+	configLock.Lock()
+	config.VCRMode = mode
+	config.VCRUpstream = upstream
+	config.VCRCassetteDir = dir
+	config.VCRMatch = match
+	configLock.Unlock()
+}
+
+func TestVCRRecordThenPlayback(t *testing.T) {
+	setupTest()
+	upstream := newTestUpstream(t)
+	dir := t.TempDir()
+	setVCRConfig(vcrModeRecord, upstream.URL, dir, "method,path,query")
+
+	req, _ := http.NewRequest("GET", "/users?id=1", nil)
+	req.Header.Set("X-Echo-Cassette", "users")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("record status: got %d want %d", rr.Code, http.StatusCreated)
+	}
+	if rr.Header().Get("X-Echo-VCR") != "recorded" || rr.Header().Get("X-Upstream") != "real" {
+		t.Errorf("unexpected record headers: %v", rr.Header())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "users.yaml")); err != nil {
+		t.Fatalf("cassette not written: %v", err)
+	}
+
+	// Playback must not touch the upstream and must come from disk
+	upstream.Close()
+	setupTest()
+	setVCRConfig(vcrModePlayback, "", dir, "method,path,query")
+	req2, _ := http.NewRequest("GET", "/users?id=1", nil)
+	req2.Header.Set("X-Echo-Cassette", "users")
+	rr2 := httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr2, req2)
+	if rr2.Code != http.StatusCreated {
+		t.Fatalf("playback status: got %d want %d", rr2.Code, http.StatusCreated)
+	}
+	if rr2.Header().Get("X-Echo-VCR") != "playback" {
+		t.Errorf("missing playback header: %v", rr2.Header())
+	}
+	if !strings.Contains(rr2.Body.String(), "upstream:GET /users?id=1") {
+		t.Errorf("unexpected playback body: %q", rr2.Body.String())
+	}
+
+	// Different query is a miss
+	req3, _ := http.NewRequest("GET", "/users?id=2", nil)
+	req3.Header.Set("X-Echo-Cassette", "users")
+	rr3 := httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr3, req3)
+	if rr3.Code != http.StatusNotFound || rr3.Header().Get("X-Echo-VCR") != "miss" {
+		t.Errorf("expected miss, got %d %v", rr3.Code, rr3.Header())
+	}
+}
+
+func TestVCRPlaybackBodyMatching(t *testing.T) {
+	setupTest()
+	upstream := newTestUpstream(t)
+	defer upstream.Close()
+	dir := t.TempDir()
+	setVCRConfig(vcrModeRecord, upstream.URL, dir, "")
+	for _, body := range []string{"alpha", "beta"} {
+		req, _ := http.NewRequest("POST", "/items", strings.NewReader(body))
+		http.HandlerFunc(echoHandler).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	setVCRConfig(vcrModePlayback, "", dir, "method,path,body")
+	req, _ := http.NewRequest("POST", "/items", strings.NewReader("beta"))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	if !strings.HasSuffix(rr.Body.String(), "beta") {
+		t.Errorf("body matching picked wrong interaction: %q", rr.Body.String())
+	}
+}
+
+func TestVCRPassthroughDoesNotRecord(t *testing.T) {
+	setupTest()
+	upstream := newTestUpstream(t)
+	defer upstream.Close()
+	dir := t.TempDir()
+	setVCRConfig(vcrModePassthrough, upstream.URL, dir, "method,path,query")
+
+	req, _ := http.NewRequest("POST", "/orders", strings.NewReader("x"))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(echoHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated || rr.Header().Get("X-Echo-VCR") != "passthrough" {
+		t.Fatalf("unexpected passthrough response: %d %v", rr.Code, rr.Header())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("passthrough should not write cassettes, found %d files", len(entries))
+	}
+}
+
+func TestVCRRedactsCredentials(t *testing.T) {
+	setupTest()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=upstream-789; HttpOnly")
+		w.Header().Set("X-Api-Key", "resp-key-000")
+		w.Header().Set("X-Upstream", "real")
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+	dir := t.TempDir()
+	setVCRConfig(vcrModeRecord, upstream.URL, dir, "method,path")
+
+	req, _ := http.NewRequest("GET", "/charges", nil)
+	req.Header.Set("X-Echo-Cassette", "secrets")
+	req.Header.Set("Authorization", "Bearer sk-live-123")
+	req.Header.Set("Proxy-Authorization", "Basic cHJveHk6cGFzcw==")
+	req.Header.Set("Cookie", "session=abc")
+	req.Header.Set("X-Api-Key", "key-456")
+	req.Header.Set("Accept", "application/json")
+	http.HandlerFunc(echoHandler).ServeHTTP(httptest.NewRecorder(), req)
+
+	data, err := os.ReadFile(filepath.Join(dir, "secrets.yaml"))
+	if err != nil {
+		t.Fatalf("cassette not written: %v", err)
+	}
+	for _, secret := range []string{"sk-live-123", "cHJveHk6cGFzcw==", "session=abc", "key-456", "upstream-789", "resp-key-000"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("cassette leaks %q:\n%s", secret, data)
+		}
+	}
+	if !strings.Contains(string(data), redactedValue) || !strings.Contains(string(data), "application/json") || !strings.Contains(string(data), "real") {
+		t.Errorf("expected redacted credentials and other headers kept:\n%s", data)
+	}
+}